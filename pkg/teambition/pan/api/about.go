@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var ErrQuotaExceeded = errors.New("not enough free space in drive")
+
+// AboutFs reports the storage quota
+type AboutFs interface {
+	About(ctx context.Context) (*Usage, error)
+}
+
+// https://pan.teambition.com/pan/api/orgs/{orgId}?orgId=
+func (teambition *Teambition) getDrive(ctx context.Context, orgId string) (*Drive, error) {
+	var drive Drive
+	err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf("https://pan.teambition.com/pan/api/orgs/%s?orgId=%s", orgId, orgId), nil, &drive)
+	if err != nil {
+		return nil, err
+	}
+	return &drive, nil
+}
+
+// selectedDrive returns the drive chosen by Config.DriveId, or the drive of the organization
+func (teambition *Teambition) selectedDrive(ctx context.Context) (*Drive, error) {
+	drives, err := teambition.ListDrives(ctx, teambition.orgId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range drives {
+		if drives[i].Data.DriveId == teambition.driveId {
+			return &drives[i], nil
+		}
+	}
+	return nil, errors.Errorf(`can't find drive "%s" in organization "%s"`, teambition.driveId, teambition.orgId)
+}
+
+func (teambition *Teambition) About(ctx context.Context) (*Usage, error) {
+	drive, err := teambition.selectedDrive(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting drive usage")
+	}
+
+	usage := &Usage{
+		Total: drive.Data.TotalSize,
+		Used:  drive.Data.UsedSize,
+		Trash: drive.Data.TrashSize,
+	}
+	if usage.Total > 0 {
+		usage.Free = usage.Total - usage.Used
+		if usage.Free < 0 {
+			usage.Free = 0
+		}
+	}
+	return usage, nil
+}
+
+func (teambition *Teambition) checkQuota(ctx context.Context, size int64) error {
+	usage, err := teambition.About(ctx)
+	if err != nil {
+		return err
+	}
+	if usage.Total > 0 && size > usage.Free {
+		return errors.Wrapf(ErrQuotaExceeded, "%d bytes requested, %d bytes free", size, usage.Free)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAboutSelectedDrive(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	driveId := "other"
+	server.handle("GET /pan/api/orgs/org", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		var drive Drive
+		drive.Data.DriveId = driveId
+		drive.Data.TotalSize = 100
+		drive.Data.UsedSize = 40
+		return http.StatusOK, drive
+	})
+
+	_, err := teambition.About(context.Background())
+	require.Error(t, err)
+	require.Error(t, teambition.checkQuota(context.Background(), 1))
+
+	driveId = "drive"
+	usage, err := teambition.About(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(60), usage.Free)
+}
+
+func TestWhoamiSelectedOrganization(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	teambition.personal = Personal{Id: "personal", Name: "Personal"}
+	server.handle("GET /api/users/me", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, User{Id: "user"}
+	})
+	server.handle("GET /api/organizations", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []Organization{{Id: "personal", Name: "Personal"}, {Id: "org", Name: "Work"}}
+	})
+
+	account, err := teambition.Whoami(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Work", account.Organization.Name)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// AccountFs reports who is logged in and whether the session is still valid
+type AccountFs interface {
+	Whoami(ctx context.Context) (*Account, error)
+	Ping(ctx context.Context) error
+}
+
+func (teambition *Teambition) me(ctx context.Context) (*User, error) {
+	var user User
+	err := teambition.jsonRequest(ctx, "GET", "https://www.teambition.com/api/users/me", nil, &user)
+	if err != nil {
+		return nil, err
+	}
+	if user.Id == "" {
+		return nil, ErrSessionExpired
+	}
+	return &user, nil
+}
+
+func (teambition *Teambition) Whoami(ctx context.Context) (*Account, error) {
+	user, err := teambition.me(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting current user")
+	}
+	org, err := teambition.selectedOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		User:         *user,
+		Organization: *org,
+		MemberId:     teambition.memberId,
+		RootId:       teambition.rootId,
+		DriveId:      teambition.driveId,
+	}, nil
+}
+
+// selectedOrganization returns the organization chosen by Config.OrgId, or the personal one
+func (teambition *Teambition) selectedOrganization(ctx context.Context) (*Personal, error) {
+	if teambition.orgId == teambition.personal.Id {
+		org := teambition.personal
+		return &org, nil
+	}
+	orgs, err := teambition.ListOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		if org.Id == teambition.orgId {
+			return &Personal{Id: org.Id, CreatorId: org.CreatorId, Name: org.Name}, nil
+		}
+	}
+	return nil, errors.Errorf(`can't find organization "%s"`, teambition.orgId)
+}
+
+// Ping verifies the session with a single cheap request, it returns ErrSessionExpired when logged out
+func (teambition *Teambition) Ping(ctx context.Context) error {
+	if _, err := teambition.me(ctx); err != nil {
+		return errors.Wrap(err, "error checking session")
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// BatchSize is the maximum number of nodes sent in one move, copy or archive request
+var BatchSize = 100
+
+// BatchConcurrency is the maximum number of batch requests in flight
+var BatchConcurrency = 4
+
+// BatchResult is the outcome of a batch operation for one node, in the same order as the input nodes.
+// Node is the moved or copied node on success, the input node otherwise.
+// Task is set when the server processes the batch of the node asynchronously
+type BatchResult struct {
+	Node *Node
+	Task *Task
+	Err  error
+}
+
+// BatchFs moves, copies and removes many nodes with as few requests as possible
+type BatchFs interface {
+	MoveMany(ctx context.Context, nodes []*Node, parent *Node) []BatchResult
+	CopyMany(ctx context.Context, nodes []*Node, parent *Node) []BatchResult
+	RemoveMany(ctx context.Context, nodes []*Node) []BatchResult
+}
+
+// runBatches splits nodes into batches of BatchSize and runs fn on them concurrently,
+// the error of a batch is reported for every node of it
+func (teambition *Teambition) runBatches(ctx context.Context, nodes []*Node, fn func(ctx context.Context, batch []*Node) ([]Node, string, error)) []BatchResult {
+	results := make([]BatchResult, len(nodes))
+	var valid []int
+	for i, node := range nodes {
+		results[i].Node = node
+		if err := teambition.checkRoot(node); err != nil {
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	size := BatchSize
+	if size < 1 {
+		size = 1
+	}
+	concurrency := BatchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for start := 0; start < len(valid); start += size {
+		end := start + size
+		if end > len(valid) {
+			end = len(valid)
+		}
+		indexes := valid[start:end]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			batch := make([]*Node, len(indexes))
+			for j, i := range indexes {
+				batch[j] = nodes[i]
+			}
+			var updated []Node
+			var task *Task
+			taskId := ""
+			err := ctx.Err()
+			if err == nil {
+				updated, taskId, err = fn(ctx, batch)
+			}
+			if err == nil && taskId != "" {
+				task = teambition.newTask(taskId, nil)
+			}
+			for j, i := range indexes {
+				results[i].Err = err
+				results[i].Task = task
+				if err == nil && j < len(updated) {
+					results[i].Node = &updated[j]
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (teambition *Teambition) MoveMany(ctx context.Context, nodes []*Node, parent *Node) []BatchResult {
+	if parent == nil {
+		return batchError(nodes, errors.New("parent node is empty"))
+	}
+	return teambition.runBatches(ctx, nodes, func(ctx context.Context, batch []*Node) ([]Node, string, error) {
+		return teambition.moveNodes(ctx, batch, parent)
+	})
+}
+
+func (teambition *Teambition) CopyMany(ctx context.Context, nodes []*Node, parent *Node) []BatchResult {
+	if parent == nil {
+		return batchError(nodes, errors.New("parent node is empty"))
+	}
+	return teambition.runBatches(ctx, nodes, func(ctx context.Context, batch []*Node) ([]Node, string, error) {
+		unlock := teambition.copyLocks.Lock(parent.NodeId)
+		defer unlock()
+		existing, err := teambition.childIds(ctx, parent)
+		if err != nil {
+			return nil, "", err
+		}
+		return teambition.copyNodes(ctx, batch, parent, existing)
+	})
+}
+
+func (teambition *Teambition) RemoveMany(ctx context.Context, nodes []*Node) []BatchResult {
+	return teambition.runBatches(ctx, nodes, func(ctx context.Context, batch []*Node) ([]Node, string, error) {
+		taskId, err := teambition.removeNodes(ctx, batch)
+		return nil, taskId, err
+	})
+}
+
+func batchError(nodes []*Node, err error) []BatchResult {
+	results := make([]BatchResult, len(nodes))
+	for i, node := range nodes {
+		results[i] = BatchResult{Node: node, Err: err}
+	}
+	return results
+}
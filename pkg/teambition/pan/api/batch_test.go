@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBatches(t *testing.T) {
+	teambition := &Teambition{rootId: "root"}
+	nodes := []*Node{{NodeId: "root"}}
+	for i := 0; i < 250; i++ {
+		nodes = append(nodes, &Node{NodeId: fmt.Sprint(i)})
+	}
+
+	var mutex sync.Mutex
+	var sizes []int
+	results := teambition.runBatches(context.Background(), nodes, func(ctx context.Context, batch []*Node) ([]Node, string, error) {
+		mutex.Lock()
+		sizes = append(sizes, len(batch))
+		mutex.Unlock()
+		if batch[0].NodeId == "0" {
+			return nil, "", errors.New("failed")
+		}
+		return nil, "", nil
+	})
+
+	require.ElementsMatch(t, []int{100, 100, 50}, sizes)
+	require.Len(t, results, len(nodes))
+	require.Error(t, results[0].Err)
+	require.Error(t, results[1].Err)
+	require.Error(t, results[100].Err)
+	require.NoError(t, results[101].Err)
+	require.Equal(t, nodes[250], results[250].Node)
+}
+
+func TestCopyManyBatchesIntoSameParent(t *testing.T) {
+	size := BatchSize
+	BatchSize = 1
+	defer func() { BatchSize = size }()
+
+	teambition, server := newFakeTeambition(t)
+	var mutex sync.Mutex
+	nodes := []Node{{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind}}
+	var sources []*Node
+	for i := 0; i < 4; i++ {
+		sources = append(sources, &Node{NodeId: fmt.Sprint("src", i), ParentId: fmt.Sprint("folder", i), Name: "a.txt", Kind: FileKind})
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Node(nil), nodes...)
+	}))
+	server.handle("POST /pan/api/nodes/copy", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		time.Sleep(10 * time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		id := body["ids"].([]interface{})[0].(map[string]interface{})["id"].(string)
+		nodes = append(nodes, Node{NodeId: "copy-" + id, ParentId: "dst", Name: fmt.Sprintf("a(%d).txt", len(nodes)), Kind: FileKind})
+		return http.StatusOK, []interface{}{}
+	})
+
+	results := teambition.CopyMany(context.Background(), sources, &nodes[0])
+	for i, result := range results {
+		require.NoError(t, result.Err)
+		require.Equal(t, "copy-"+sources[i].NodeId, result.Node.NodeId)
+	}
+}
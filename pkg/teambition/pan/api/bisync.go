@@ -0,0 +1,648 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const syncStateVersion = 1
+
+const defaultSyncStateName = tempFilePrefix + "sync.json"
+
+// SyncEntry is the snapshot of one path taken at the end of the last successful sync
+type SyncEntry struct {
+	NodeId       string    `json:"nodeId"`
+	Name         string    `json:"name"`
+	IsDir        bool      `json:"isDir,omitempty"`
+	Size         int64     `json:"size"`
+	Updated      string    `json:"updated"`
+	LocalSize    int64     `json:"localSize"`
+	LocalModTime time.Time `json:"localModTime"`
+}
+
+// SyncState maps slash separated paths, relative to the synced folders, to their last synced snapshot
+type SyncState struct {
+	Version int                   `json:"version"`
+	Entries map[string]*SyncEntry `json:"entries"`
+}
+
+func LoadSyncState(path string) (*SyncState, error) {
+	state := &SyncState{Version: syncStateVersion, Entries: map[string]*SyncEntry{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, `error reading sync state "%s"`, path)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrapf(err, `error parsing sync state "%s"`, path)
+	}
+	if state.Version != syncStateVersion {
+		return nil, errors.Errorf(`unsupported sync state version %d in "%s"`, state.Version, path)
+	}
+	if state.Entries == nil {
+		state.Entries = map[string]*SyncEntry{}
+	}
+	return state, nil
+}
+
+func (state *SyncState) Save(path string) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "error marshalling sync state")
+	}
+	temp := path + ".tmp"
+	if err := ioutil.WriteFile(temp, b, 0600); err != nil {
+		return errors.Wrapf(err, `error writing sync state "%s"`, temp)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		return errors.Wrapf(err, `error renaming sync state to "%s"`, path)
+	}
+	return nil
+}
+
+type SyncOptions struct {
+	// StatePath is the file keeping the last synced snapshot, defaults to ".teambition-sync.json" inside the local folder
+	StatePath string
+}
+
+// Sync synchronizes the remote folder and the local directory in both directions.
+// Changes are detected against the snapshot saved by the previous run. When a file changed on both sides,
+// the local copy is renamed with a conflict suffix and uploaded, and the remote copy is downloaded in its place.
+// Renames and moves of remote nodes are detected by NodeId, renames and moves of local files by size and modification time.
+func Sync(ctx context.Context, fs Fs, remote string, local string, options *SyncOptions) error {
+	if options == nil {
+		options = &SyncOptions{}
+	}
+	statePath := options.StatePath
+	if statePath == "" {
+		statePath = filepath.Join(local, defaultSyncStateName)
+	}
+
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return errors.Wrapf(err, `error creating local folder "%s"`, local)
+	}
+
+	state, err := LoadSyncState(statePath)
+	if err != nil {
+		return err
+	}
+
+	s := &syncer{
+		fs:          fs,
+		remote:      normalizePath(remote),
+		local:       local,
+		state:       state,
+		remoteNodes: map[string]*Node{},
+		remoteIds:   map[string]string{},
+		localFiles:  map[string]*localFile{},
+	}
+	if err := s.scanRemote(ctx, ""); err != nil {
+		return err
+	}
+	if err := s.scanLocal(); err != nil {
+		return err
+	}
+
+	err = s.run(ctx)
+	if serr := state.Save(statePath); err == nil {
+		err = serr
+	}
+	return err
+}
+
+type localFile struct {
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+type change int
+
+const (
+	unchanged change = iota
+	created
+	modified
+	deleted
+)
+
+type syncer struct {
+	fs          Fs
+	remote      string
+	local       string
+	state       *SyncState
+	remoteNodes map[string]*Node
+	remoteIds   map[string]string
+	localFiles  map[string]*localFile
+	skipped     []string
+}
+
+func relJoin(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// movedKey returns the key after moving oldPrefix to newPrefix, if key is oldPrefix or lies under it
+func movedKey(key string, oldPrefix string, newPrefix string) (string, bool) {
+	if key == oldPrefix {
+		return newPrefix, true
+	}
+	if strings.HasPrefix(key, oldPrefix+"/") {
+		return newPrefix + key[len(oldPrefix):], true
+	}
+	return "", false
+}
+
+func (s *syncer) remotePath(rel string) string {
+	if rel == "" {
+		return s.remote
+	}
+	return joinPath(s.remote, rel)
+}
+
+func (s *syncer) localPath(rel string) string {
+	return filepath.Join(s.local, filepath.FromSlash(rel))
+}
+
+func (s *syncer) scanRemote(ctx context.Context, rel string) error {
+	nodes, err := s.fs.List(ctx, s.remotePath(rel))
+	if err != nil {
+		return errors.Wrapf(err, `error listing "%s"`, s.remotePath(rel))
+	}
+	for i := range nodes {
+		node := &nodes[i]
+		if err := checkLocalName(node.Name); err != nil {
+			return errors.Wrapf(err, `error syncing "%s"`, s.remotePath(rel))
+		}
+		r := relJoin(rel, node.Name)
+		s.remoteNodes[r] = node
+		s.remoteIds[node.NodeId] = r
+		if node.IsDirectory() {
+			if err := s.scanRemote(ctx, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *syncer) scanLocal() error {
+	return filepath.Walk(s.local, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == s.local {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), tempFilePrefix) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.local, p)
+		if err != nil {
+			return err
+		}
+		s.localFiles[filepath.ToSlash(rel)] = &localFile{IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+}
+
+func (s *syncer) localChange(rel string) change {
+	e, l := s.state.Entries[rel], s.localFiles[rel]
+	switch {
+	case e == nil && l == nil:
+		return unchanged
+	case e == nil:
+		return created
+	case l == nil:
+		return deleted
+	case e.IsDir != l.IsDir:
+		return modified
+	case !l.IsDir && (l.Size != e.LocalSize || !l.ModTime.Equal(e.LocalModTime)):
+		return modified
+	}
+	return unchanged
+}
+
+func (s *syncer) remoteChange(rel string) change {
+	e, r := s.state.Entries[rel], s.remoteNodes[rel]
+	switch {
+	case e == nil && r == nil:
+		return unchanged
+	case e == nil:
+		return created
+	case r == nil:
+		return deleted
+	case e.IsDir != r.IsDirectory() || e.NodeId != r.NodeId:
+		return modified
+	case !e.IsDir && (r.Size != e.Size || r.Updated != e.Updated):
+		return modified
+	}
+	return unchanged
+}
+
+func (s *syncer) isSkipped(rel string) bool {
+	for _, prefix := range s.skipped {
+		if strings.HasPrefix(rel, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rekeyState moves state entries of oldRel and its children to newRel
+func (s *syncer) rekeyState(oldRel string, newRel string) {
+	moved := map[string]*SyncEntry{}
+	for key, entry := range s.state.Entries {
+		if k, ok := movedKey(key, oldRel, newRel); ok {
+			delete(s.state.Entries, key)
+			moved[k] = entry
+		}
+	}
+	for key, entry := range moved {
+		s.state.Entries[key] = entry
+	}
+	if entry, ok := s.state.Entries[newRel]; ok {
+		entry.Name = path.Base(newRel)
+	}
+}
+
+// applyRemoteMoves renames local paths whose remote node moved since the last sync
+func (s *syncer) applyRemoteMoves() error {
+	keys := make([]string, 0, len(s.state.Entries))
+	for key := range s.state.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, rel := range keys {
+		entry, ok := s.state.Entries[rel]
+		if !ok {
+			continue
+		}
+		newRel, ok := s.remoteIds[entry.NodeId]
+		if !ok || newRel == rel {
+			continue
+		}
+		if _, exists := s.localFiles[newRel]; exists {
+			continue
+		}
+		if _, exists := s.state.Entries[newRel]; exists {
+			continue
+		}
+		if l, ok := s.localFiles[rel]; !ok || l.IsDir != entry.IsDir || s.localChange(rel) != unchanged {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(s.localPath(newRel)), 0755); err != nil {
+			return errors.Wrapf(err, `error creating local folder of "%s"`, newRel)
+		}
+		if err := os.Rename(s.localPath(rel), s.localPath(newRel)); err != nil {
+			return errors.Wrapf(err, `error moving local "%s" to "%s"`, rel, newRel)
+		}
+		moved := map[string]*localFile{}
+		for key, l := range s.localFiles {
+			if k, ok := movedKey(key, rel, newRel); ok {
+				delete(s.localFiles, key)
+				moved[k] = l
+			}
+		}
+		for key, l := range moved {
+			s.localFiles[key] = l
+		}
+		s.rekeyState(rel, newRel)
+	}
+	return nil
+}
+
+// remoteFolder returns the remote folder node of rel, creating it when missing
+func (s *syncer) remoteFolder(ctx context.Context, rel string) (*Node, error) {
+	if rel == "" || rel == "." {
+		return s.fs.Get(ctx, s.remote, FolderKind)
+	}
+	return s.fs.CreateFolder(ctx, s.remotePath(rel))
+}
+
+// applyLocalMoves renames or moves remote files whose local file was renamed or moved since the last sync
+func (s *syncer) applyLocalMoves(ctx context.Context) error {
+	var added []string
+	for rel, l := range s.localFiles {
+		if _, ok := s.state.Entries[rel]; !ok && !l.IsDir {
+			if _, ok := s.remoteNodes[rel]; !ok {
+				added = append(added, rel)
+			}
+		}
+	}
+	sort.Strings(added)
+
+	keys := make([]string, 0, len(s.state.Entries))
+	for key := range s.state.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, rel := range keys {
+		entry := s.state.Entries[rel]
+		if entry.IsDir || s.localChange(rel) != deleted || s.remoteChange(rel) != unchanged {
+			continue
+		}
+
+		match := -1
+		for i, candidate := range added {
+			l := s.localFiles[candidate]
+			if l.Size == entry.LocalSize && l.ModTime.Equal(entry.LocalModTime) {
+				if match >= 0 {
+					match = -1
+					break
+				}
+				match = i
+			}
+		}
+		if match < 0 {
+			continue
+		}
+		newRel := added[match]
+		added = append(added[:match], added[match+1:]...)
+
+		node := s.remoteNodes[rel]
+		oldDir, newDir := path.Dir(rel), path.Dir(newRel)
+		if oldDir != newDir {
+			parent, err := s.remoteFolder(ctx, newDir)
+			if err != nil {
+				return errors.Wrapf(err, `error resolving remote folder of "%s"`, newRel)
+			}
+			if _, err := s.fs.Move(ctx, node, parent); err != nil {
+				return errors.Wrapf(err, `error moving "%s" to "%s"`, rel, newRel)
+			}
+		}
+		if name := path.Base(newRel); name != node.Name {
+			if err := s.fs.Rename(ctx, node, name); err != nil {
+				return errors.Wrapf(err, `error renaming "%s" to "%s"`, rel, newRel)
+			}
+			node.Name = name
+		}
+
+		delete(s.remoteNodes, rel)
+		s.remoteNodes[newRel] = node
+		s.remoteIds[node.NodeId] = newRel
+		s.rekeyState(rel, newRel)
+	}
+	return nil
+}
+
+func (s *syncer) run(ctx context.Context) error {
+	if err := s.applyRemoteMoves(); err != nil {
+		return err
+	}
+	if err := s.applyLocalMoves(ctx); err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	for rel := range s.state.Entries {
+		set[rel] = true
+	}
+	for rel := range s.remoteNodes {
+		set[rel] = true
+	}
+	for rel := range s.localFiles {
+		set[rel] = true
+	}
+	paths := make([]string, 0, len(set))
+	for rel := range set {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	localChanges := make(map[string]change, len(paths))
+	remoteChanges := make(map[string]change, len(paths))
+	localDirty := map[string]bool{}
+	remoteDirty := map[string]bool{}
+	for _, rel := range paths {
+		lc, rc := s.localChange(rel), s.remoteChange(rel)
+		localChanges[rel], remoteChanges[rel] = lc, rc
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			if lc != unchanged && lc != deleted {
+				localDirty[dir] = true
+			}
+			if rc != unchanged && rc != deleted {
+				remoteDirty[dir] = true
+			}
+		}
+	}
+
+	deleteLocal := map[string]bool{}
+	deleteRemote := map[string]bool{}
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.isSkipped(rel) {
+			continue
+		}
+
+		var err error
+		lc, rc := localChanges[rel], remoteChanges[rel]
+		switch {
+		case lc == unchanged && rc == unchanged:
+		case lc == deleted && rc == deleted:
+			delete(s.state.Entries, rel)
+		case lc == deleted && rc == unchanged:
+			if s.state.Entries[rel].IsDir && remoteDirty[rel] {
+				err = s.download(ctx, rel)
+			} else {
+				deleteRemote[rel] = true
+			}
+		case lc == unchanged && rc == deleted:
+			if s.state.Entries[rel].IsDir && localDirty[rel] {
+				err = s.upload(ctx, rel)
+			} else {
+				deleteLocal[rel] = true
+			}
+		case rc == unchanged || rc == deleted:
+			err = s.upload(ctx, rel)
+		case lc == unchanged || lc == deleted:
+			err = s.download(ctx, rel)
+		case s.localFiles[rel].IsDir && s.remoteNodes[rel].IsDirectory():
+			err = s.record(rel, s.remoteNodes[rel])
+		case sameFile(s.localFiles[rel], s.remoteNodes[rel]):
+			// created or changed the same way on both sides, like a folder mirrored by SyncDown
+			err = s.record(rel, s.remoteNodes[rel])
+		default:
+			err = s.resolveConflict(ctx, rel)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := len(paths) - 1; i >= 0; i-- {
+		rel := paths[i]
+		parent := path.Dir(rel)
+		if deleteRemote[rel] {
+			if !deleteRemote[parent] {
+				if err := s.fs.Remove(ctx, s.remoteNodes[rel]); err != nil {
+					return errors.Wrapf(err, `error removing remote "%s"`, rel)
+				}
+			}
+			delete(s.state.Entries, rel)
+		}
+		if deleteLocal[rel] {
+			if !deleteLocal[parent] {
+				if err := os.RemoveAll(s.localPath(rel)); err != nil {
+					return errors.Wrapf(err, `error removing local "%s"`, rel)
+				}
+			}
+			delete(s.state.Entries, rel)
+		}
+	}
+
+	return nil
+}
+
+// sameFile reports whether the local file matches the remote node by size and modification time
+func sameFile(l *localFile, node *Node) bool {
+	if l.IsDir || node.IsDirectory() || l.Size != node.Size {
+		return false
+	}
+	t, err := node.GetTime()
+	if err != nil {
+		return false
+	}
+	return l.ModTime.Truncate(time.Second).Equal(t.Truncate(time.Second))
+}
+
+// record saves the snapshot of rel after both sides are in sync
+func (s *syncer) record(rel string, node *Node) error {
+	info, err := os.Stat(s.localPath(rel))
+	if err != nil {
+		return errors.Wrapf(err, `error reading local "%s"`, rel)
+	}
+	entry := &SyncEntry{
+		NodeId:  node.NodeId,
+		Name:    node.Name,
+		IsDir:   node.IsDirectory(),
+		Size:    node.Size,
+		Updated: node.Updated,
+	}
+	if !entry.IsDir {
+		entry.LocalSize = info.Size()
+		entry.LocalModTime = info.ModTime()
+	}
+	s.state.Entries[rel] = entry
+	s.remoteNodes[rel] = node
+	s.remoteIds[node.NodeId] = rel
+	return nil
+}
+
+func (s *syncer) upload(ctx context.Context, rel string) error {
+	l := s.localFiles[rel]
+	if r, ok := s.remoteNodes[rel]; ok && r.IsDirectory() != l.IsDir {
+		if err := s.fs.Remove(ctx, r); err != nil {
+			return errors.Wrapf(err, `error removing remote "%s"`, rel)
+		}
+	}
+
+	if l.IsDir {
+		node, err := s.fs.CreateFolder(ctx, s.remotePath(rel))
+		if err != nil {
+			return errors.Wrapf(err, `error creating remote folder "%s"`, rel)
+		}
+		return s.record(rel, node)
+	}
+
+	in, err := os.Open(s.localPath(rel))
+	if err != nil {
+		return errors.Wrapf(err, `error opening local "%s"`, rel)
+	}
+	defer in.Close()
+
+	node, err := s.fs.CreateFile(ctx, s.remotePath(rel), l.Size, in, true)
+	if err != nil {
+		return errors.Wrapf(err, `error uploading "%s"`, rel)
+	}
+	if node.Updated == "" {
+		if n, err := s.fs.Get(ctx, s.remotePath(rel), FileKind); err == nil {
+			node = n
+		}
+	}
+	return s.record(rel, node)
+}
+
+func (s *syncer) download(ctx context.Context, rel string) error {
+	node := s.remoteNodes[rel]
+	localPath := s.localPath(rel)
+	if l, ok := s.localFiles[rel]; ok && l.IsDir != node.IsDirectory() {
+		if err := os.RemoveAll(localPath); err != nil {
+			return errors.Wrapf(err, `error removing local "%s"`, rel)
+		}
+	}
+
+	if node.IsDirectory() {
+		if err := os.MkdirAll(localPath, 0755); err != nil {
+			return errors.Wrapf(err, `error creating local folder "%s"`, rel)
+		}
+		return s.record(rel, node)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return errors.Wrapf(err, `error creating local folder of "%s"`, rel)
+	}
+	if err := downloadFile(ctx, s.fs, node, localPath); err != nil {
+		return errors.Wrapf(err, `error downloading "%s"`, rel)
+	}
+	return s.record(rel, node)
+}
+
+// conflictName returns name with a conflict suffix inserted before its extension
+func conflictName(name string, t time.Time) string {
+	ext := path.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (conflict %s)%s", strings.TrimSuffix(name, ext), t.Format("2006-01-02 150405"), ext)
+}
+
+// resolveConflict keeps both versions: the local one under a conflict name, the remote one under the original name
+func (s *syncer) resolveConflict(ctx context.Context, rel string) error {
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	}
+	conflictRel := relJoin(dir, conflictName(path.Base(rel), time.Now()))
+	if err := os.Rename(s.localPath(rel), s.localPath(conflictRel)); err != nil {
+		return errors.Wrapf(err, `error renaming conflicting "%s"`, rel)
+	}
+
+	l := s.localFiles[rel]
+	delete(s.localFiles, rel)
+	if l.IsDir {
+		// the content of a renamed folder is picked up as new local files by the next sync
+		s.skipped = append(s.skipped, rel)
+	} else {
+		s.localFiles[conflictRel] = l
+		if err := s.upload(ctx, conflictRel); err != nil {
+			return err
+		}
+	}
+
+	return s.download(ctx, rel)
+}
@@ -1,41 +1,291 @@
 package api
 
 import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	lru "github.com/hashicorp/golang-lru"
 )
 
 type FolderCache interface {
 	Get(string) (*Node, bool)
+	// Lookup is Get which also reports whether the entry is older than the time-to-live of the cache
+	Lookup(string) (node *Node, stale bool, ok bool)
 	Put(string, *Node)
+	Remove(string)
+	Keys() []string
+	// InvalidatePrefix removes path and every path under it
+	InvalidatePrefix(path string)
+	// MovePrefix re-keys oldPath and every path under it to newPath
+	MovePrefix(oldPath string, newPath string)
 	Clear()
+	Stats() CacheStats
+	// Entries returns the cached paths and nodes, least recently used first
+	Entries() []CacheEntry
+}
+
+// CacheStats counts the lookups and removals of a FolderCache since it was created
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	// Evictions are entries dropped to make room for new ones
+	Evictions int64
+	// Invalidations are entries dropped because they may be outdated
+	Invalidations int64
+	Size          int
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("CacheStats{Hits: %d, Misses: %d, Evictions: %d, Invalidations: %d, Size: %d}", s.Hits, s.Misses, s.Evictions, s.Invalidations, s.Size)
+}
+
+type CacheEntry struct {
+	Path   string
+	Node   *Node
+	Stored time.Time
+}
+
+type folderEntry struct {
+	node   *Node
+	stored time.Time
 }
 
 type CacheImpl struct {
-	cache *lru.Cache
+	// the counters come first to be 64-bit aligned for sync/atomic
+	hits          int64
+	misses        int64
+	evictions     int64
+	invalidations int64
+	cache         *lru.Cache
+	ttl           time.Duration
 }
 
 func NewCache(size int) (FolderCache, error) {
+	return NewCacheWithTTL(size, 0)
+}
+
+// NewCacheWithTTL creates a FolderCache whose entries become stale after ttl, 0 means they never do
+func NewCacheWithTTL(size int, ttl time.Duration) (FolderCache, error) {
 	c, err := lru.New(size)
 	if err != nil {
 		return nil, err
 	}
-	return &CacheImpl{cache: c}, nil
+	return &CacheImpl{cache: c, ttl: ttl}, nil
 }
 
 func (c *CacheImpl) Get(key string) (*Node, bool) {
+	node, _, ok := c.Lookup(key)
+	return node, ok
+}
+
+func (c *CacheImpl) Lookup(key string) (*Node, bool, bool) {
 	value, ok := c.cache.Get(key)
-	if !ok {
-		return nil, ok
+	entry, isEntry := value.(*folderEntry)
+	if !ok || !isEntry {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false, false
 	}
-
-	node, ok := value.(*Node)
-	return node, ok
+	atomic.AddInt64(&c.hits, 1)
+	stale := c.ttl > 0 && time.Since(entry.stored) > c.ttl
+	return entry.node, stale, true
 }
 
 func (c *CacheImpl) Put(key string, node *Node) {
-	c.cache.Add(key, node)
+	c.add(key, &folderEntry{node: node, stored: time.Now()})
+}
+
+func (c *CacheImpl) add(key string, entry interface{}) {
+	if c.cache.Add(key, entry) {
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+func (c *CacheImpl) Remove(key string) {
+	if c.cache.Remove(key) {
+		atomic.AddInt64(&c.invalidations, 1)
+	}
+}
+
+func (c *CacheImpl) Keys() []string {
+	keys := c.cache.Keys()
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if s, ok := key.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// isUnder reports whether key is path or lies under it
+func isUnder(key string, path string) bool {
+	return key == path || strings.HasPrefix(key, path+"/")
+}
+
+func (c *CacheImpl) InvalidatePrefix(path string) {
+	for _, key := range c.Keys() {
+		if isUnder(key, path) {
+			c.Remove(key)
+		}
+	}
+}
+
+func (c *CacheImpl) MovePrefix(oldPath string, newPath string) {
+	c.InvalidatePrefix(newPath)
+	for _, key := range c.Keys() {
+		if !isUnder(key, oldPath) {
+			continue
+		}
+		value, ok := c.cache.Peek(key)
+		c.cache.Remove(key)
+		if ok {
+			c.add(newPath+key[len(oldPath):], value)
+		}
+	}
 }
 
 func (c *CacheImpl) Clear() {
+	atomic.AddInt64(&c.invalidations, int64(c.cache.Len()))
+	c.cache.Purge()
+}
+
+func (c *CacheImpl) Stats() CacheStats {
+	return CacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Evictions:     atomic.LoadInt64(&c.evictions),
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+		Size:          c.cache.Len(),
+	}
+}
+
+func (c *CacheImpl) Entries() []CacheEntry {
+	var entries []CacheEntry
+	for _, key := range c.Keys() {
+		value, ok := c.cache.Peek(key)
+		if entry, isEntry := value.(*folderEntry); ok && isEntry {
+			entries = append(entries, CacheEntry{Path: key, Node: entry.node, Stored: entry.stored})
+		}
+	}
+	return entries
+}
+
+// CacheFs exposes the folder cache of an Fs, for debugging
+type CacheFs interface {
+	FolderCache() FolderCache
+}
+
+// ListingCache caches the children of folders keyed by the folder NodeId
+type ListingCache interface {
+	Get(nodeId string) ([]Node, bool)
+	Put(nodeId string, nodes []Node)
+	Remove(nodeId string)
+	Clear()
+}
+
+type listingEntry struct {
+	nodes   []Node
+	expires time.Time
+}
+
+type ListingCacheImpl struct {
+	cache *lru.Cache
+	ttl   time.Duration
+}
+
+func NewListingCache(size int, ttl time.Duration) (ListingCache, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &ListingCacheImpl{cache: c, ttl: ttl}, nil
+}
+
+func (c *ListingCacheImpl) Get(nodeId string) ([]Node, bool) {
+	value, ok := c.cache.Get(nodeId)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := value.(*listingEntry)
+	if !ok || time.Now().After(entry.expires) {
+		c.cache.Remove(nodeId)
+		return nil, false
+	}
+	return append([]Node(nil), entry.nodes...), true
+}
+
+func (c *ListingCacheImpl) Put(nodeId string, nodes []Node) {
+	c.cache.Add(nodeId, &listingEntry{nodes: append([]Node(nil), nodes...), expires: time.Now().Add(c.ttl)})
+}
+
+func (c *ListingCacheImpl) Remove(nodeId string) {
+	c.cache.Remove(nodeId)
+}
+
+func (c *ListingCacheImpl) Clear() {
+	c.cache.Purge()
+}
+
+// NegativeCache remembers names missing from folders keyed by the folder NodeId
+type NegativeCache interface {
+	Missing(parentId string, name string, kind string) bool
+	Put(parentId string, name string, kind string)
+	Remove(parentId string)
+	Clear()
+}
+
+type negativeEntry struct {
+	mutex sync.Mutex
+	names map[string]time.Time
+}
+
+type NegativeCacheImpl struct {
+	cache *lru.Cache
+	ttl   time.Duration
+}
+
+func NewNegativeCache(size int, ttl time.Duration) (NegativeCache, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &NegativeCacheImpl{cache: c, ttl: ttl}, nil
+}
+
+func (c *NegativeCacheImpl) Missing(parentId string, name string, kind string) bool {
+	value, ok := c.cache.Get(parentId)
+	if !ok {
+		return false
+	}
+	entry := value.(*negativeEntry)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	key := kind + ":" + name
+	expires, ok := entry.names[key]
+	if ok && time.Now().After(expires) {
+		delete(entry.names, key)
+		return false
+	}
+	return ok
+}
+
+func (c *NegativeCacheImpl) Put(parentId string, name string, kind string) {
+	entry := &negativeEntry{names: map[string]time.Time{}}
+	if previous, ok, _ := c.cache.PeekOrAdd(parentId, entry); ok {
+		entry = previous.(*negativeEntry)
+	}
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	entry.names[kind+":"+name] = time.Now().Add(c.ttl)
+}
+
+func (c *NegativeCacheImpl) Remove(parentId string) {
+	c.cache.Remove(parentId)
+}
+
+func (c *NegativeCacheImpl) Clear() {
 	c.cache.Purge()
 }
@@ -1,7 +1,13 @@
 package api
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCache(t *testing.T) {
@@ -16,3 +22,234 @@ func TestCache(t *testing.T) {
 		t.Errorf(`"%s" should be cleaned, but still get "%s"`, "a", v)
 	}
 }
+
+func TestUpdateMovedFolder(t *testing.T) {
+	c, _ := NewCache(16)
+	teambition := &Teambition{rootId: "root", folderCache: c}
+	c.Put("/a", &Node{Name: "a", NodeId: "1"})
+	c.Put("/a/b", &Node{Name: "b", NodeId: "2"})
+	c.Put("/a/b/c", &Node{Name: "c", NodeId: "3"})
+	c.Put("/d", &Node{Name: "d", NodeId: "4"})
+
+	teambition.updateMovedFolder("/a/b", &Node{Name: "b", NodeId: "2", ParentId: "4"}, &Node{NodeId: "4"})
+	if _, ok := c.Get("/a/b"); ok {
+		t.Errorf(`"%s" should be removed`, "/a/b")
+	}
+	if v, ok := c.Get("/d/b/c"); !ok || v.NodeId != "3" {
+		t.Errorf(`"%s" should be moved, but get "%v"`, "/d/b/c", v)
+	}
+	if v, ok := c.Get("/d/b"); !ok || v.ParentId != "4" {
+		t.Errorf(`"%s" should be updated, but get "%v"`, "/d/b", v)
+	}
+}
+
+func TestInvalidatePrefix(t *testing.T) {
+	c, _ := NewCache(16)
+	c.Put("/a", &Node{Name: "a"})
+	c.Put("/a/b", &Node{Name: "b"})
+	c.Put("/ab", &Node{Name: "ab"})
+	c.InvalidatePrefix("/a")
+	if _, ok := c.Get("/a/b"); ok {
+		t.Errorf(`"%s" should be invalidated`, "/a/b")
+	}
+	if _, ok := c.Get("/ab"); !ok {
+		t.Errorf(`"%s" should be kept`, "/ab")
+	}
+}
+
+func TestListingCache(t *testing.T) {
+	c, _ := NewListingCache(2, 50*time.Millisecond)
+	c.Put("a", []Node{{Name: "x"}})
+	if nodes, ok := c.Get("a"); !ok || len(nodes) != 1 {
+		t.Errorf(`failed to get listing of "%s"`, "a")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf(`listing of "%s" should be expired`, "a")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, _ := NewCacheWithTTL(2, 50*time.Millisecond)
+	c.Put("a", &Node{Name: "a"})
+	if _, stale, ok := c.Lookup("a"); !ok || stale {
+		t.Errorf(`"%s" should be fresh`, "a")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, stale, ok := c.Lookup("a"); !ok || !stale {
+		t.Errorf(`"%s" should be stale`, "a")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+
+	c, err := OpenDiskCache(path, "drive/root", 16, 0, time.Minute, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("/a", &Node{Name: "a", NodeId: "1"})
+	c.Listings().Put("1", []Node{{Name: "x"}})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ = OpenDiskCache(path, "drive/root", 16, 0, time.Minute, false)
+	if v, ok := c.Get("/a"); !ok || v.NodeId != "1" {
+		t.Errorf(`"%s" should be loaded, but get "%v"`, "/a", v)
+	}
+	if nodes, ok := c.Listings().Get("1"); !ok || len(nodes) != 1 {
+		t.Errorf(`listing of "%s" should be loaded`, "1")
+	}
+
+	c, _ = OpenDiskCache(path, "drive/root", 16, 0, time.Minute, true)
+	if _, ok := c.Get("/a"); ok {
+		t.Errorf(`"%s" should be ignored when refreshing`, "/a")
+	}
+	c, _ = OpenDiskCache(path, "other/root", 16, 0, time.Minute, false)
+	if _, ok := c.Get("/a"); ok {
+		t.Errorf(`"%s" should be ignored in another scope`, "/a")
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	c, _ := NewNegativeCache(16, 50*time.Millisecond)
+	c.Put("1", "a", FileKind)
+	if !c.Missing("1", "a", FileKind) {
+		t.Errorf(`"%s" should be missing`, "a")
+	}
+	if c.Missing("1", "a", FolderKind) {
+		t.Errorf(`"%s" of another kind should not be missing`, "a")
+	}
+	c.Remove("1")
+	if c.Missing("1", "a", FileKind) {
+		t.Errorf(`"%s" should be forgotten`, "a")
+	}
+	c.Put("1", "b", FileKind)
+	time.Sleep(60 * time.Millisecond)
+	if c.Missing("1", "b", FileKind) {
+		t.Errorf(`"%s" should be expired`, "b")
+	}
+}
+
+func TestInvalidateListingsClearsMisses(t *testing.T) {
+	negativeCache, _ := NewNegativeCache(16, time.Minute)
+	teambition := &Teambition{negativeCache: negativeCache}
+	negativeCache.Put("1", "a", FileKind)
+	negativeCache.Put("2", "b", FileKind)
+	teambition.invalidateListings("1")
+	if negativeCache.Missing("1", "a", FileKind) {
+		t.Errorf(`"%s" should be forgotten after a change of its parent`, "a")
+	}
+	if !negativeCache.Missing("2", "b", FileKind) {
+		t.Errorf(`"%s" should still be missing`, "b")
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	c, _ := NewCache(2)
+	c.Put("/a", &Node{Name: "a"})
+	c.Get("/a")
+	c.Get("/b")
+	c.Put("/b", &Node{Name: "b"})
+	c.Put("/c", &Node{Name: "c"})
+	c.InvalidatePrefix("/b")
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Invalidations != 1 || stats.Size != 1 {
+		t.Errorf("unexpected %s", stats)
+	}
+	if entries := c.Entries(); len(entries) != 1 || entries[0].Path != "/c" {
+		t.Errorf(`entries should only hold "%s", but get "%v"`, "/c", entries)
+	}
+}
+
+func TestPathOfCached(t *testing.T) {
+	c, _ := NewCache(16)
+	teambition := &Teambition{rootId: "root", folderCache: c}
+	c.Put("/a", &Node{Name: "a", NodeId: "1", Kind: FolderKind})
+
+	path, err := teambition.PathOf(context.Background(), &Node{Name: "b", NodeId: "2", ParentId: "1", Kind: FolderKind})
+	if err != nil || path != "/a/b" {
+		t.Errorf(`path should be "%s", but get "%s", %v`, "/a/b", path, err)
+	}
+	if v, ok := c.Get("/a/b"); !ok || v.NodeId != "2" {
+		t.Errorf(`"%s" should be cached`, "/a/b")
+	}
+	if path, _ := teambition.PathOf(context.Background(), &Node{NodeId: "root"}); path != "/" {
+		t.Errorf(`path of root should be "/", but get "%s"`, path)
+	}
+}
+
+func TestDiskCacheMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+
+	c, _ := OpenDiskCache(path, "drive/root", 16, 0, time.Minute, false)
+	c.cache.Add("/old", &folderEntry{node: &Node{Name: "old"}, stored: time.Now().Add(-2 * DiskCacheMaxAge)})
+	c.Put("/new", &Node{Name: "new"})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ = OpenDiskCache(path, "drive/root", 16, 0, time.Minute, false)
+	if _, ok := c.Get("/old"); ok {
+		t.Errorf(`"%s" is older than DiskCacheMaxAge and should not be loaded`, "/old")
+	}
+	if _, ok := c.Get("/new"); !ok {
+		t.Errorf(`"%s" should be loaded`, "/new")
+	}
+}
+
+func TestCachedPathKeepsStats(t *testing.T) {
+	c, _ := NewCache(16)
+	teambition := &Teambition{rootId: "root", folderCache: c}
+	c.Put("/a", &Node{NodeId: "1"})
+	c.Put("/b", &Node{NodeId: "2"})
+	if path, ok := teambition.cachedPath(&Node{NodeId: "1"}); !ok || path != "/a" {
+		t.Errorf(`path should be "%s", but get "%s"`, "/a", path)
+	}
+	teambition.cachedPath(&Node{NodeId: "3"})
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("cachedPath should not count as lookups, but get %s", stats)
+	}
+	if keys := c.Keys(); keys[0] != "/a" {
+		t.Errorf(`"%s" should still be the least recently used, but get %v`, "/a", keys)
+	}
+}
+
+func TestDiskCacheWithoutDownloadUrl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+
+	c, _ := OpenDiskCache(path, "drive/root", 16, 0, time.Minute, false)
+	c.Put("/a", &Node{Name: "a", NodeId: "1", DownloadUrl: "https://example.com/a?Signature=secret"})
+	c.Listings().Put("1", []Node{{Name: "x", DownloadUrl: "https://example.com/x?Signature=secret"}})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "Signature") {
+		t.Errorf("download urls should not be saved, but get %s", b)
+	}
+	if nodes, _ := c.Listings().Get("1"); nodes[0].DownloadUrl == "" {
+		t.Errorf("saving should not change the cached nodes")
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ImportedCookies are the teambition.com cookies found in a browser export
+type ImportedCookies struct {
+	Cookies []*http.Cookie
+	// Expires is the earliest expiry among Cookies, zero when all of them are session cookies
+	Expires time.Time
+}
+
+func (imported *ImportedCookies) String() string {
+	return fmt.Sprintf("ImportedCookies{Count: %d, Expires: %s}", len(imported.Cookies), imported.Expires)
+}
+
+// Config builds a Config using the imported cookies
+func (imported *ImportedCookies) Config() *Config {
+	return &Config{Cookie: formatCookie(imported.Cookies)}
+}
+
+func isTeambitionDomain(domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	return domain == CookieDomain || strings.HasSuffix(domain, "."+CookieDomain)
+}
+
+// newImportedCookies keeps the unexpired teambition.com cookies, later cookies replace earlier ones of the same name
+func newImportedCookies(cookies []*http.Cookie) (*ImportedCookies, error) {
+	now := time.Now()
+	index := map[string]int{}
+	imported := &ImportedCookies{}
+	for _, c := range cookies {
+		if !isTeambitionDomain(c.Domain) || (!c.Expires.IsZero() && c.Expires.Before(now)) {
+			continue
+		}
+		if i, ok := index[c.Name]; ok {
+			imported.Cookies[i] = c
+			continue
+		}
+		index[c.Name] = len(imported.Cookies)
+		imported.Cookies = append(imported.Cookies, c)
+	}
+	if len(imported.Cookies) < 1 {
+		return nil, errors.Errorf("no unexpired %s cookies found", CookieDomain)
+	}
+
+	for _, c := range imported.Cookies {
+		if !c.Expires.IsZero() && (imported.Expires.IsZero() || c.Expires.Before(imported.Expires)) {
+			imported.Expires = c.Expires
+		}
+	}
+	return imported, nil
+}
+
+// ImportNetscapeCookies reads a Netscape format cookies.txt as written by curl, wget and browser extensions
+func ImportNetscapeCookies(r io.Reader) (*ImportedCookies, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, errors.Errorf(`invalid cookies.txt line: "%s"`, line)
+		}
+		c := &http.Cookie{
+			Domain: fields[0],
+			Path:   fields[2],
+			Secure: strings.EqualFold(fields[3], "TRUE"),
+			Name:   fields[5],
+			Value:  fields[6],
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading cookies.txt")
+	}
+	return newImportedCookies(cookies)
+}
+
+type jsonCookie struct {
+	Domain         string  `json:"domain"`
+	ExpirationDate float64 `json:"expirationDate"`
+	Name           string  `json:"name"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	Value          string  `json:"value"`
+}
+
+// ImportJSONCookies reads a JSON cookie export in the EditThisCookie format
+func ImportJSONCookies(r io.Reader) (*ImportedCookies, error) {
+	var exported []jsonCookie
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, errors.Wrap(err, "error parsing JSON cookies")
+	}
+	cookies := make([]*http.Cookie, len(exported))
+	for i, e := range exported {
+		cookies[i] = &http.Cookie{Domain: e.Domain, Path: e.Path, Secure: e.Secure, Name: e.Name, Value: e.Value}
+		if e.ExpirationDate > 0 {
+			cookies[i].Expires = time.Unix(int64(e.ExpirationDate), 0)
+		}
+	}
+	return newImportedCookies(cookies)
+}
+
+type harCookie struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Domain  string `json:"domain"`
+	Path    string `json:"path"`
+	Expires string `json:"expires"`
+}
+
+type har struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Url     string      `json:"url"`
+				Cookies []harCookie `json:"cookies"`
+			} `json:"request"`
+			Response struct {
+				Cookies []harCookie `json:"cookies"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// ImportHARCookies reads the cookies sent to and set by teambition.com in a HAR file saved from the browser DevTools
+func ImportHARCookies(r io.Reader) (*ImportedCookies, error) {
+	var h har
+	if err := json.NewDecoder(r).Decode(&h); err != nil {
+		return nil, errors.Wrap(err, "error parsing HAR")
+	}
+
+	var cookies []*http.Cookie
+	for _, entry := range h.Log.Entries {
+		u, err := url.Parse(entry.Request.Url)
+		if err != nil {
+			continue
+		}
+		for _, e := range append(entry.Request.Cookies, entry.Response.Cookies...) {
+			c := &http.Cookie{Domain: e.Domain, Path: e.Path, Name: e.Name, Value: e.Value}
+			if c.Domain == "" {
+				c.Domain = u.Hostname()
+			}
+			if t, err := time.Parse(time.RFC3339, e.Expires); err == nil {
+				c.Expires = t
+			}
+			cookies = append(cookies, c)
+		}
+	}
+	return newImportedCookies(cookies)
+}
+
+// ImportCookieFile detects the format of a cookies.txt, JSON cookie export or HAR file and imports it
+func ImportCookieFile(path string) (*ImportedCookies, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error reading "%s"`, path)
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return ImportJSONCookies(bytes.NewReader(trimmed))
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return ImportHARCookies(bytes.NewReader(trimmed))
+	default:
+		return ImportNetscapeCookies(bytes.NewReader(trimmed))
+	}
+}
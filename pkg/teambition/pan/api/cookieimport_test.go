@@ -0,0 +1,40 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportNetscapeCookies(t *testing.T) {
+	txt := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_.teambition.com\tTRUE\t/\tTRUE\t4102444800\tTEAMBITION_SESSIONID\tsession\n" +
+		".teambition.com\tTRUE\t/\tTRUE\t4070908800\tTEAMBITION_SESSIONID.sig\tsig\n" +
+		".example.com\tTRUE\t/\tFALSE\t4102444800\tother\tvalue\n" +
+		".teambition.com\tTRUE\t/\tFALSE\t1\texpired\tvalue\n"
+	imported, err := ImportNetscapeCookies(strings.NewReader(txt))
+	require.NoError(t, err)
+	require.Equal(t, "TEAMBITION_SESSIONID=session; TEAMBITION_SESSIONID.sig=sig", imported.Config().Cookie)
+	require.True(t, imported.Expires.Equal(time.Unix(4070908800, 0)))
+}
+
+func TestImportJSONCookies(t *testing.T) {
+	js := `[{"domain":".teambition.com","expirationDate":4102444800.5,"name":"TEAMBITION_SESSIONID","path":"/","value":"session"},
+		{"domain":"www.example.com","name":"other","path":"/","value":"value","session":true}]`
+	imported, err := ImportJSONCookies(strings.NewReader(js))
+	require.NoError(t, err)
+	require.Equal(t, "TEAMBITION_SESSIONID=session", imported.Config().Cookie)
+}
+
+func TestImportHARCookies(t *testing.T) {
+	js := `{"log":{"entries":[
+		{"request":{"url":"https://www.teambition.com/api/organizations/personal","cookies":[{"name":"TEAMBITION_SESSIONID","value":"old"}]},"response":{"cookies":[]}},
+		{"request":{"url":"https://pan.teambition.com/pan/api/spaces","cookies":[]},"response":{"cookies":[{"name":"TEAMBITION_SESSIONID","value":"new","domain":".teambition.com","expires":"2100-01-01T00:00:00Z"}]}},
+		{"request":{"url":"https://www.example.com/","cookies":[{"name":"other","value":"value"}]},"response":{"cookies":[]}}]}}`
+	imported, err := ImportHARCookies(strings.NewReader(js))
+	require.NoError(t, err)
+	require.Equal(t, "TEAMBITION_SESSIONID=new", imported.Config().Cookie)
+	require.Equal(t, 2100, imported.Expires.Year())
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFindsRenamedCopy(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	var mutex sync.Mutex
+	nodes := []Node{
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "old", ParentId: "dst", Name: "2.jpg", Kind: FileKind},
+		{NodeId: "src", ParentId: "root", Name: "2.jpg", Kind: FileKind},
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Node(nil), nodes...)
+	}))
+	server.handle("POST /pan/api/nodes/copy", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		nodes = append(nodes, Node{NodeId: "new", ParentId: "dst", Name: "2(1).jpg", Kind: FileKind})
+		return http.StatusOK, []interface{}{}
+	})
+
+	copied, err := teambition.Copy(context.Background(), &nodes[2], &nodes[0])
+	require.NoError(t, err)
+	require.Equal(t, "new", copied.NodeId)
+}
+
+func TestCopyWithoutNewNode(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	nodes := []Node{
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "old", ParentId: "dst", Name: "2.jpg", Kind: FileKind},
+		{NodeId: "src", ParentId: "root", Name: "2.jpg", Kind: FileKind},
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nodes }))
+	server.handle("POST /pan/api/nodes/copy", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []interface{}{}
+	})
+
+	_, err := teambition.Copy(context.Background(), &nodes[2], &nodes[0])
+	require.Error(t, err)
+}
+
+func TestCopyWaitsForTask(t *testing.T) {
+	interval := TaskPollInterval
+	TaskPollInterval = time.Millisecond
+	defer func() { TaskPollInterval = interval }()
+
+	teambition, server := newFakeTeambition(t)
+	var mutex sync.Mutex
+	nodes := []Node{
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "src", ParentId: "root", Name: "a", Kind: FolderKind},
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Node(nil), nodes...)
+	}))
+	server.handle("POST /pan/api/nodes/copy", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]string{"taskId": "t"}
+	})
+	polls := 0
+	server.handle("GET /pan/api/tasks/t", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		polls++
+		if polls < 3 {
+			return http.StatusOK, TaskStatus{TaskId: "t", Status: TaskRunning}
+		}
+		if polls == 3 {
+			nodes = append(nodes, Node{NodeId: "new", ParentId: "dst", Name: "a", Kind: FolderKind})
+		}
+		return http.StatusOK, TaskStatus{TaskId: "t", Status: TaskSucceeded}
+	})
+
+	copied, err := teambition.Copy(context.Background(), &nodes[1], &nodes[0])
+	require.NoError(t, err)
+	require.Equal(t, "new", copied.NodeId)
+	require.Equal(t, 3, polls)
+}
+
+func TestMoveUnknownTaskStatus(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	server.handle("POST /pan/api/nodes/move", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]string{"taskId": "t"}
+	})
+	server.handle("GET /pan/api/tasks/t", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]string{"message": "no such task"}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := teambition.Move(ctx, &Node{NodeId: "src", ParentId: "root", Name: "a"}, &Node{NodeId: "dst", Name: "dst"})
+	require.Error(t, err)
+	require.NotEqual(t, context.DeadlineExceeded, err)
+	require.Equal(t, 1, server.count("GET /pan/api/tasks/t"))
+}
+
+func TestIsCopyName(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		original string
+		copy     bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"a(1).txt", "a.txt", true},
+		{"a (12).txt", "a.txt", true},
+		{"a(1)", "a", true},
+		{".bashrc(1)", ".bashrc", true},
+		{"a b(1).txt", "a.txt", false},
+		{"a().txt", "a.txt", false},
+		{"a(x).txt", "a.txt", false},
+		{"a(1).txt.txt", "a.txt", false},
+		{"a.txt(1)", "a.txt", false},
+	} {
+		require.Equal(t, c.copy, isCopyName(c.name, c.original), "%s of %s", c.name, c.original)
+	}
+}
+
+func TestCopySimilarNames(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	var mutex sync.Mutex
+	nodes := []Node{
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "old1", ParentId: "dst", Name: "a b.txt", Kind: FileKind},
+		{NodeId: "old2", ParentId: "dst", Name: "a.txt", Kind: FileKind},
+		{NodeId: "src1", ParentId: "root", Name: "a.txt", Kind: FileKind},
+		{NodeId: "src2", ParentId: "root", Name: "a b.txt", Kind: FileKind},
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Node(nil), nodes...)
+	}))
+	server.handle("POST /pan/api/nodes/copy", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		// listed by name like the server does
+		nodes = append(nodes[:3], append([]Node{
+			{NodeId: "new2", ParentId: "dst", Name: "a b(1).txt", Kind: FileKind},
+			{NodeId: "new1", ParentId: "dst", Name: "a(1).txt", Kind: FileKind},
+		}, nodes[3:]...)...)
+		return http.StatusOK, []interface{}{}
+	})
+
+	results := teambition.CopyMany(context.Background(), []*Node{&nodes[3], &nodes[4]}, &nodes[0])
+	require.NoError(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	require.Equal(t, "new1", results[0].Node.NodeId)
+	require.Equal(t, "new2", results[1].Node.NodeId)
+}
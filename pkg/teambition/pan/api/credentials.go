@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// CredentialProvider supplies the session cookie, it is consulted by NewFs and again when the session expired
+type CredentialProvider interface {
+	Cookie(ctx context.Context) (string, error)
+}
+
+// DefaultCookieEnv is the environment variable read by EnvCredentials when Name is empty
+const DefaultCookieEnv = "TEAMBITION_COOKIE"
+
+// EnvCredentials reads the cookie from an environment variable
+type EnvCredentials struct {
+	Name string
+}
+
+func (p EnvCredentials) Cookie(ctx context.Context) (string, error) {
+	name := p.Name
+	if name == "" {
+		name = DefaultCookieEnv
+	}
+	cookie := strings.TrimSpace(os.Getenv(name))
+	if cookie == "" {
+		return "", errors.Errorf(`environment variable "%s" is empty`, name)
+	}
+	return cookie, nil
+}
+
+// FileCredentials reads the cookie from a file which must not be accessible by group or others
+type FileCredentials struct {
+	Path string
+}
+
+func checkPrivate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, `error reading "%s"`, path)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return errors.Errorf(`permissions %v of "%s" are too open, it must be accessible by the owner only`, info.Mode().Perm(), path)
+	}
+	return nil
+}
+
+func (p FileCredentials) Cookie(ctx context.Context) (string, error) {
+	if err := checkPrivate(p.Path); err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return "", errors.Wrapf(err, `error reading "%s"`, p.Path)
+	}
+	cookie := strings.TrimSpace(string(b))
+	if cookie == "" {
+		return "", errors.Errorf(`"%s" is empty`, p.Path)
+	}
+	return cookie, nil
+}
+
+// encryptedMagic starts the files written by EncryptCookie, whose key is derived with scrypt
+const encryptedMagic = "TBCRED2\n"
+const encryptedSaltSize = 16
+
+// deriveCredentialsKey derives the AES-256 key from passphrase
+func deriveCredentialsKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return key, nil
+}
+
+func newCredentialsCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveCredentialsKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return aead, nil
+}
+
+// EncryptCookie encrypts the cookie with AES-GCM using a key derived from passphrase, for EncryptedFileCredentials
+func EncryptCookie(cookie string, passphrase string) ([]byte, error) {
+	salt := make([]byte, encryptedSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+	aead, err := newCredentialsCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+
+	out := append([]byte(encryptedMagic), salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, []byte(cookie), []byte(encryptedMagic)), nil
+}
+
+// DecryptCookie reverses EncryptCookie
+func DecryptCookie(data []byte, passphrase string) (string, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return "", errors.New("not an encrypted credentials file")
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < encryptedSaltSize {
+		return "", errors.New("encrypted credentials are truncated")
+	}
+	salt, data := data[:encryptedSaltSize], data[encryptedSaltSize:]
+	aead, err := newCredentialsCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("encrypted credentials are truncated")
+	}
+	nonce, data := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, data, []byte(encryptedMagic))
+	if err != nil {
+		return "", errors.New("error decrypting credentials, wrong passphrase?")
+	}
+	return string(plain), nil
+}
+
+// EncryptedFileCredentials reads the cookie from a file written with EncryptCookie
+type EncryptedFileCredentials struct {
+	Path       string
+	Passphrase string
+}
+
+func (p EncryptedFileCredentials) Cookie(ctx context.Context) (string, error) {
+	if err := checkPrivate(p.Path); err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return "", errors.Wrapf(err, `error reading "%s"`, p.Path)
+	}
+	cookie, err := DecryptCookie(b, p.Passphrase)
+	if err != nil {
+		return "", errors.Wrapf(err, `error decrypting "%s"`, p.Path)
+	}
+	return cookie, nil
+}
+
+// CommandCredentials runs a command like "pass show teambition" and uses the first line of its output as the cookie
+type CommandCredentials struct {
+	Command string
+	Args    []string
+}
+
+func (p CommandCredentials) Cookie(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, `error running "%s": %s`, p.Command, strings.TrimSpace(stderr.String()))
+	}
+	cookie := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	if cookie == "" {
+		return "", errors.Errorf(`"%s" printed no cookie`, p.Command)
+	}
+	return cookie, nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data, err := EncryptCookie("TEAMBITION_SESSIONID=xxx", "secret")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "cookie.enc")
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	cookie, err := EncryptedFileCredentials{Path: path, Passphrase: "secret"}.Cookie(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "TEAMBITION_SESSIONID=xxx", cookie)
+
+	_, err = EncryptedFileCredentials{Path: path, Passphrase: "wrong"}.Cookie(context.Background())
+	require.Error(t, err)
+
+	require.NoError(t, os.Chmod(path, 0644))
+	_, err = FileCredentials{Path: path}.Cookie(context.Background())
+	require.Error(t, err)
+}
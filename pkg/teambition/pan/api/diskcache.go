@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DiskCacheMaxAge is the age after which folder paths saved by a previous run are not loaded anymore,
+// when the folder cache has no time-to-live shorter than that
+var DiskCacheMaxAge = time.Hour
+
+// DiskCacheVersion is bumped whenever the format of the cache file changes, files of other versions are ignored
+const DiskCacheVersion = 1
+
+type diskCacheFile struct {
+	Version  int                `json:"version"`
+	Scope    string             `json:"scope"`
+	Folders  []diskFolderEntry  `json:"folders"`
+	Listings []diskListingEntry `json:"listings"`
+}
+
+type diskFolderEntry struct {
+	Path   string    `json:"path"`
+	Node   *Node     `json:"node"`
+	Stored time.Time `json:"stored"`
+}
+
+type diskListingEntry struct {
+	NodeId  string    `json:"nodeId"`
+	Nodes   []Node    `json:"nodes"`
+	Expires time.Time `json:"expires"`
+}
+
+// DiskCache is a FolderCache whose entries, and those of its listing cache, are saved to a JSON file by Save
+// and loaded again by OpenDiskCache so that they survive restarts
+type DiskCache struct {
+	*CacheImpl
+	listings *ListingCacheImpl
+	path     string
+	scope    string
+}
+
+// OpenDiskCache loads the cache file at path, scope identifies the drive and root the entries belong to.
+// The file is ignored when refresh is set, or when it was written by another version or for another scope.
+func OpenDiskCache(path string, scope string, size int, folderTTL time.Duration, listTTL time.Duration, refresh bool) (*DiskCache, error) {
+	folders, err := NewCacheWithTTL(size, folderTTL)
+	if err != nil {
+		return nil, err
+	}
+	listings, err := NewListingCache(size, listTTL)
+	if err != nil {
+		return nil, err
+	}
+	c := &DiskCache{
+		CacheImpl: folders.(*CacheImpl),
+		listings:  listings.(*ListingCacheImpl),
+		path:      path,
+		scope:     scope,
+	}
+	if refresh {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, `error reading cache "%s"`, path)
+	}
+	var file diskCacheFile
+	if err := json.Unmarshal(b, &file); err != nil || file.Version != DiskCacheVersion || file.Scope != scope {
+		return c, nil
+	}
+
+	maxAge := DiskCacheMaxAge
+	if folderTTL > 0 && folderTTL < maxAge {
+		maxAge = folderTTL
+	}
+	for _, entry := range file.Folders {
+		if entry.Node != nil && time.Since(entry.Stored) < maxAge {
+			c.cache.Add(entry.Path, &folderEntry{node: entry.Node, stored: entry.Stored})
+		}
+	}
+	now := time.Now()
+	for _, entry := range file.Listings {
+		if entry.Expires.After(now) {
+			c.listings.cache.Add(entry.NodeId, &listingEntry{nodes: entry.Nodes, expires: entry.Expires})
+		}
+	}
+	return c, nil
+}
+
+// Listings returns the listing cache saved along with the folders
+func (c *DiskCache) Listings() ListingCache {
+	return c.listings
+}
+
+// withoutDownloadUrl returns a copy of node without its signed download url, which is secret and expires soon
+func withoutDownloadUrl(node Node) Node {
+	node.DownloadUrl = ""
+	return node
+}
+
+// Save writes the entries to the cache file, least recently used first so that loading keeps their order.
+// Download urls are not saved.
+func (c *DiskCache) Save() error {
+	file := diskCacheFile{Version: DiskCacheVersion, Scope: c.scope}
+	for _, entry := range c.Entries() {
+		node := withoutDownloadUrl(*entry.Node)
+		file.Folders = append(file.Folders, diskFolderEntry{Path: entry.Path, Node: &node, Stored: entry.Stored})
+	}
+	now := time.Now()
+	for _, key := range c.listings.cache.Keys() {
+		value, ok := c.listings.cache.Peek(key)
+		entry, isEntry := value.(*listingEntry)
+		nodeId, isString := key.(string)
+		if ok && isEntry && isString && entry.expires.After(now) {
+			nodes := make([]Node, len(entry.nodes))
+			for i := range entry.nodes {
+				nodes[i] = withoutDownloadUrl(entry.nodes[i])
+			}
+			file.Listings = append(file.Listings, diskListingEntry{NodeId: nodeId, Nodes: nodes, Expires: entry.expires})
+		}
+	}
+
+	b, err := json.Marshal(&file)
+	if err != nil {
+		return errors.Wrap(err, "error marshalling cache")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, `error saving cache "%s"`, c.path)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		return errors.Wrapf(err, `error saving cache "%s"`, c.path)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// fakeServer answers the requests of a Teambition in offline tests, by "METHOD path" without the query.
+// Handlers return a value encoded as JSON, or []byte sent as is
+type fakeServer struct {
+	mutex    sync.Mutex
+	handlers map[string]func(req *http.Request, body map[string]interface{}) (int, interface{})
+	calls    map[string]int
+}
+
+func (f *fakeServer) handle(route string, handler func(req *http.Request, body map[string]interface{}) (int, interface{})) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.handlers[route] = handler
+}
+
+func (f *fakeServer) count(route string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls[route]
+}
+
+func (f *fakeServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := req.Method + " " + req.URL.Path
+	f.mutex.Lock()
+	f.calls[route]++
+	handler, ok := f.handlers[route]
+	f.mutex.Unlock()
+
+	var body map[string]interface{}
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		_ = json.Unmarshal(b, &body)
+	}
+	status, response := http.StatusNotFound, interface{}(map[string]string{"message": "not found"})
+	if ok {
+		status, response = handler(req, body)
+	}
+	b, isRaw := response.([]byte)
+	if !isRaw {
+		var err error
+		if b, err = json.Marshal(response); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(b)),
+		Request:    req,
+	}, nil
+}
+
+// newFakeTeambition returns a Teambition whose requests are answered by the returned fakeServer
+func newFakeTeambition(t *testing.T) (*Teambition, *fakeServer) {
+	server := &fakeServer{
+		handlers: map[string]func(req *http.Request, body map[string]interface{}) (int, interface{}){},
+		calls:    map[string]int{},
+	}
+	cache, err := NewCache(DefaultFolderCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	teambition := &Teambition{
+		folderCache: cache,
+		rootId:      "root",
+		rootNode:    Node{NodeId: "root", Kind: FolderKind, Name: "Root"},
+		orgId:       "org",
+		driveId:     "drive",
+		httpClient:  &http.Client{Transport: server},
+	}
+	return teambition, server
+}
+
+// listRoute is the route of listNodes, answered with the nodes whose ParentId is the parentId of the query
+func listRoute(nodes func() []Node) func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+	return func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		parentId := req.URL.Query().Get("parentId")
+		var children []Node
+		for _, node := range nodes() {
+			if node.ParentId == parentId {
+				children = append(children, node)
+			}
+		}
+		return http.StatusOK, Nodes{Data: children}
+	}
+}
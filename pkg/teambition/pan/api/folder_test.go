@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFolderIgnoresStaleListing(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	listings, err := NewListingCache(16, time.Minute)
+	require.NoError(t, err)
+	teambition.listingCache = listings
+	listings.Put("root", nil)
+	nodes := []Node{{NodeId: "a", ParentId: "root", Name: "a", Kind: FolderKind}}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nodes }))
+
+	node, err := teambition.CreateFolder(context.Background(), "/a")
+	require.NoError(t, err)
+	require.Equal(t, "a", node.NodeId)
+	require.Equal(t, 0, server.count("POST /pan/api/nodes/folder"))
+}
+
+func TestCreateFolderRefused(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	var mutex sync.Mutex
+	var nodes []Node
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Node(nil), nodes...)
+	}))
+	// another client creates the folder right before this one
+	server.handle("POST /pan/api/nodes/folder", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		nodes = append(nodes, Node{NodeId: "a", ParentId: "root", Name: "a", Kind: FolderKind})
+		return http.StatusConflict, map[string]string{"message": "already exists"}
+	})
+
+	node, err := teambition.CreateFolder(context.Background(), "/a")
+	require.NoError(t, err)
+	require.Equal(t, "a", node.NodeId)
+}
+
+func TestListingInvalidatedWhileFetching(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	listings, err := NewListingCache(16, time.Minute)
+	require.NoError(t, err)
+	teambition.listingCache = listings
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	server.handle("GET /pan/api/nodes", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		close(fetching)
+		<-release
+		return http.StatusOK, Nodes{}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := teambition.listNodes(context.Background(), &teambition.rootNode)
+		require.NoError(t, err)
+	}()
+	<-fetching
+	teambition.invalidateListings("root")
+	close(release)
+	<-done
+
+	_, ok := listings.Get("root")
+	require.False(t, ok, "a listing fetched before the invalidation should not be cached")
+}
+
+func TestCreateFolderServerError(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nil }))
+	server.handle("POST /pan/api/nodes/folder", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusInternalServerError, []byte{}
+	})
+
+	_, err := teambition.CreateFolder(context.Background(), "/a")
+	require.Error(t, err)
+}
+
+func TestCreateFolderUsesCachedListing(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	listings, err := NewListingCache(16, time.Minute)
+	require.NoError(t, err)
+	teambition.listingCache = listings
+	nodes := []Node{
+		{NodeId: "a", ParentId: "root", Name: "a", Kind: FolderKind},
+		{NodeId: "b", ParentId: "a", Name: "b", Kind: FolderKind},
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nodes }))
+
+	for i := 0; i < 3; i++ {
+		node, err := teambition.CreateFolder(context.Background(), "/a/b")
+		require.NoError(t, err)
+		require.Equal(t, "b", node.NodeId)
+	}
+	require.Equal(t, 2, server.count("GET /pan/api/nodes"))
+}
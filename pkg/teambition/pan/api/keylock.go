@@ -0,0 +1,40 @@
+package api
+
+import "sync"
+
+type keyLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// keyedMutex locks keys independently of each other, a lock is dropped once nobody holds or waits for it
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+// Lock locks key and returns the function unlocking it
+func (m *keyedMutex) Lock(key string) func() {
+	m.mutex.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyLock{}
+	}
+	lock, ok := m.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		m.locks[key] = lock
+	}
+	lock.refs++
+	m.mutex.Unlock()
+
+	lock.mutex.Lock()
+	return func() {
+		lock.mutex.Unlock()
+		m.mutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mutex.Unlock()
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var m keyedMutex
+	unlock := m.Lock("/a")
+
+	done := make(chan struct{})
+	go func() {
+		m.Lock("/b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf(`locking "%s" should not wait for "%s"`, "/b", "/a")
+	}
+
+	var wg sync.WaitGroup
+	locked := false
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.Lock("/a")()
+		locked = true
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if locked {
+		t.Errorf(`"%s" should be locked`, "/a")
+	}
+	unlock()
+	wg.Wait()
+	if !locked || len(m.locks) != 0 {
+		t.Errorf("locks should be released, but %d are left", len(m.locks))
+	}
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// memFs is an in-memory Fs for offline tests
+type memFs struct {
+	mutex  sync.Mutex
+	prefix string
+	nextId int
+	nodes  map[string]*Node
+	data   map[string][]byte
+	// truncated are the NodeIds whose content Open cuts short, like a failed download
+	truncated map[string]bool
+}
+
+func newMemFs(prefix string) *memFs {
+	return &memFs{
+		prefix:    prefix,
+		nodes:     map[string]*Node{prefix + "root": {NodeId: prefix + "root", Kind: FolderKind, Name: "Root"}},
+		data:      map[string][]byte{},
+		truncated: map[string]bool{},
+	}
+}
+
+func (f *memFs) rootId() string {
+	return f.prefix + "root"
+}
+
+func (f *memFs) newNode(parentId string, name string, kind string) *Node {
+	f.nextId++
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	node := &Node{
+		NodeId:      fmt.Sprintf("%s%d", f.prefix, f.nextId),
+		ParentId:    parentId,
+		Name:        name,
+		Kind:        kind,
+		Updated:     now.Format(nodeTimeLayout),
+		UpdatedTime: now,
+	}
+	f.nodes[node.NodeId] = node
+	return node
+}
+
+func (f *memFs) children(parentId string) []Node {
+	var nodes []Node
+	for _, node := range f.nodes {
+		if node.ParentId == parentId && node.NodeId != f.rootId() {
+			nodes = append(nodes, *node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+func (f *memFs) child(parentId string, name string) *Node {
+	for _, node := range f.nodes {
+		if node.ParentId == parentId && node.Name == name && node.NodeId != f.rootId() {
+			return node
+		}
+	}
+	return nil
+}
+
+func (f *memFs) lookup(path string) (*Node, error) {
+	node := f.nodes[f.rootId()]
+	for _, name := range strings.Split(strings.Trim(normalizePath(path), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if node = f.child(node.NodeId, name); node == nil {
+			return nil, errors.Errorf(`can't find "%s"`, path)
+		}
+	}
+	return node, nil
+}
+
+func (f *memFs) Get(ctx context.Context, path string, kind string) (*Node, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	node, err := f.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if kind != AnyKind && node.Kind != kind {
+		return nil, errors.Errorf(`"%s" is not a %s`, path, kind)
+	}
+	result := *node
+	return &result, nil
+}
+
+func (f *memFs) List(ctx context.Context, path string) ([]Node, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	node, err := f.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.children(node.NodeId), nil
+}
+
+func (f *memFs) createFolder(path string) *Node {
+	node := f.nodes[f.rootId()]
+	for _, name := range strings.Split(strings.Trim(normalizePath(path), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		child := f.child(node.NodeId, name)
+		if child == nil {
+			child = f.newNode(node.NodeId, name, FolderKind)
+		}
+		node = child
+	}
+	return node
+}
+
+func (f *memFs) CreateFolder(ctx context.Context, path string) (*Node, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	result := *f.createFolder(path)
+	return &result, nil
+}
+
+func (f *memFs) Rename(ctx context.Context, node *Node, newName string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	n, ok := f.nodes[node.NodeId]
+	if !ok {
+		return errors.Errorf(`unknown node "%s"`, node)
+	}
+	n.Name = newName
+	return nil
+}
+
+func (f *memFs) Move(ctx context.Context, node *Node, parent *Node) (*Node, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	n, ok := f.nodes[node.NodeId]
+	if !ok {
+		return nil, errors.Errorf(`unknown node "%s"`, node)
+	}
+	n.ParentId = parent.NodeId
+	result := *n
+	return &result, nil
+}
+
+func (f *memFs) remove(nodeId string) {
+	for _, child := range f.children(nodeId) {
+		f.remove(child.NodeId)
+	}
+	delete(f.nodes, nodeId)
+	delete(f.data, nodeId)
+}
+
+func (f *memFs) Remove(ctx context.Context, node *Node) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.nodes[node.NodeId]; !ok {
+		return errors.Errorf(`unknown node "%s"`, node)
+	}
+	f.remove(node.NodeId)
+	return nil
+}
+
+func (f *memFs) Open(ctx context.Context, node *Node, headers map[string]string) (io.ReadCloser, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	data, ok := f.data[node.NodeId]
+	if !ok {
+		return nil, errors.Errorf(`unknown file "%s"`, node)
+	}
+	if f.truncated[node.NodeId] {
+		data = data[:len(data)/2]
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (f *memFs) CreateFile(ctx context.Context, path string, size int64, in io.Reader, overwrite bool) (*Node, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	path = normalizePath(path)
+	i := strings.LastIndex(path, "/")
+	parent := f.createFolder(path[:i])
+	name := path[i+1:]
+	if existing := f.child(parent.NodeId, name); existing != nil {
+		if !overwrite {
+			return nil, errors.Errorf(`"%s" exists`, path)
+		}
+		f.remove(existing.NodeId)
+	}
+	node := f.newNode(parent.NodeId, name, FileKind)
+	node.Size = int64(len(data))
+	f.data[node.NodeId] = data
+	result := *node
+	return &result, nil
+}
+
+func (f *memFs) copy(node *Node, parentId string) *Node {
+	copied := f.newNode(parentId, node.Name, node.Kind)
+	copied.Size = node.Size
+	if data, ok := f.data[node.NodeId]; ok {
+		f.data[copied.NodeId] = data
+	}
+	for _, child := range f.children(node.NodeId) {
+		f.copy(&child, copied.NodeId)
+	}
+	return copied
+}
+
+func (f *memFs) Copy(ctx context.Context, node *Node, parent *Node) (*Node, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	n, ok := f.nodes[node.NodeId]
+	if !ok {
+		return nil, errors.Errorf(`unknown node "%s"`, node)
+	}
+	result := *f.copy(n, parent.NodeId)
+	return &result, nil
+}
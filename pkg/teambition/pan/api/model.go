@@ -1,29 +1,88 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"time"
 )
 
 type Personal struct {
 	Id        string `json:"_id"`
 	CreatorId string `json:"_creatorId"`
+	Name      string `json:"name"`
 }
 
 func (p Personal) String() string {
-	return fmt.Sprintf("Personal{Id: %s, CreatorId: %s}", p.Id, p.CreatorId)
+	return fmt.Sprintf("Personal{Id: %s, CreatorId: %s, Name: %s}", p.Id, p.CreatorId, p.Name)
+}
+
+type User struct {
+	Id        string `json:"_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarUrl string `json:"avatarUrl,omitempty"`
+}
+
+func (u User) String() string {
+	return fmt.Sprintf("User{Id: %s, Name: %s}", u.Id, u.Name)
+}
+
+type Account struct {
+	User         User
+	Organization Personal
+	MemberId     string
+	RootId       string
+	DriveId      string
+}
+
+func (a Account) String() string {
+	return fmt.Sprintf("Account{User: %s, Organization: %s, MemberId: %s, DriveId: %s}", a.User, a.Organization, a.MemberId, a.DriveId)
+}
+
+type Organization struct {
+	Id         string `json:"_id"`
+	Name       string `json:"name"`
+	CreatorId  string `json:"_creatorId"`
+	IsPersonal bool   `json:"isPersonal"`
+}
+
+func (o Organization) String() string {
+	return fmt.Sprintf("Organization{Id: %s, Name: %s}", o.Id, o.Name)
 }
 
 type Space struct {
-	RootId string `json:"rootId"`
+	SpaceId string `json:"spaceId"`
+	Name    string `json:"name"`
+	RootId  string `json:"rootId"`
+}
+
+func (s Space) String() string {
+	return fmt.Sprintf("Space{SpaceId: %s, Name: %s, RootId: %s}", s.SpaceId, s.Name, s.RootId)
 }
 
 type Drive struct {
 	Data struct {
-		DriveId string `json:"driveId"`
+		DriveId   string `json:"driveId"`
+		TotalSize int64  `json:"totalSize"`
+		UsedSize  int64  `json:"usedSize"`
+		TrashSize int64  `json:"trashSize"`
 	} `json:"data"`
 }
 
+// Usage is the storage quota of the drive in bytes, Total is 0 when the drive is unlimited
+type Usage struct {
+	Total int64
+	Used  int64
+	Free  int64
+	Trash int64
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("Usage{Total: %d, Used: %d, Free: %d, Trash: %d}", u.Total, u.Used, u.Free, u.Trash)
+}
+
 type Node struct {
 	DownloadUrl string `json:"downloadUrl,omitempty"`
 	Kind        string `json:"kind"`
@@ -32,6 +91,109 @@ type Node struct {
 	ParentId    string `json:"parentId,omitempty"`
 	Size        int64  `json:"size,omitempty"`
 	Updated     string `json:"updated"`
+	Created     string `json:"created,omitempty"`
+	CreatorId   string `json:"creatorId,omitempty"`
+	ModifierId  string `json:"modifierId,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Ext         string `json:"ext,omitempty"`
+	// Category is the kind of content of a file, like "image", "video" or "doc"
+	Category    string `json:"category,omitempty"`
+	Thumbnail   string `json:"thumbnail,omitempty"`
+	ContentHash string `json:"contentHash,omitempty"`
+	// UpdatedTime and CreatedTime are parsed from Updated and Created, zero when they are missing or malformed
+	UpdatedTime time.Time `json:"-"`
+	CreatedTime time.Time `json:"-"`
+	// Extra holds the fields of the response not mapped above
+	Extra map[string]json.RawMessage `json:"-"`
+	// alias is the account of a MultiFs the node was obtained from
+	alias string
+}
+
+// nodeTimeLayout is the layout of Updated and Created, timestamps in other layouts are normalized to it
+const nodeTimeLayout = "2006-01-02T15:04:05.000Z"
+
+var nodeTimeLayouts = []string{nodeTimeLayout, time.RFC3339Nano, "2006-01-02 15:04:05"}
+
+// parseNodeTime parses a timestamp which is either a string in one of nodeTimeLayouts or unix milliseconds
+func parseNodeTime(raw json.RawMessage) (string, time.Time) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		var ms int64
+		if err := json.Unmarshal(raw, &ms); err != nil || ms == 0 {
+			return "", time.Time{}
+		}
+		t := time.Unix(ms/1000, ms%1000*int64(time.Millisecond)).UTC()
+		return t.Format(nodeTimeLayout), t
+	}
+	for _, layout := range nodeTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return s, t
+		}
+	}
+	return s, time.Time{}
+}
+
+// nodeFields has no methods so that encoding/json handles it the default way
+type nodeFields Node
+
+// nodeKeys are the json names of the fields of Node
+var nodeKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(Node{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+func (n *Node) UnmarshalJSON(b []byte) error {
+	var node struct {
+		*nodeFields
+		Updated json.RawMessage `json:"updated"`
+		Created json.RawMessage `json:"created"`
+	}
+	node.nodeFields = (*nodeFields)(n)
+	if err := json.Unmarshal(b, &node); err != nil {
+		return err
+	}
+	n.Updated, n.UpdatedTime = parseNodeTime(node.Updated)
+	n.Created, n.CreatedTime = parseNodeTime(node.Created)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	n.Extra = nil
+	for key, value := range fields {
+		if !nodeKeys[key] {
+			if n.Extra == nil {
+				n.Extra = map[string]json.RawMessage{}
+			}
+			n.Extra[key] = value
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes Extra back along with the other fields
+func (n Node) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(nodeFields(n))
+	if err != nil || len(n.Extra) == 0 {
+		return b, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range n.Extra {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
 }
 
 func (n Node) String() string {
@@ -47,13 +209,15 @@ func (n *Node) IsDirectory() bool {
 }
 
 func (n *Node) GetTime() (time.Time, error) {
-	layout := "2006-01-02T15:04:05.000Z"
-	t, err := time.Parse(layout, n.Updated)
-
-	if err != nil {
-		return time.Time{}, err
+	if !n.UpdatedTime.IsZero() {
+		return n.UpdatedTime, nil
 	}
-	return t, nil
+	for _, layout := range nodeTimeLayouts {
+		if t, err := time.Parse(layout, n.Updated); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(nodeTimeLayout, n.Updated)
 }
 
 type Nodes struct {
@@ -66,3 +230,62 @@ type UploadResult struct {
 	UploadId  string   `json:"uploadId"`
 	UploadUrl []string `json:"uploadUrl"`
 }
+
+func (r UploadResult) String() string {
+	return fmt.Sprintf("UploadResult{Name: %s, NodeId: %s, UploadId: %s}", r.Name, r.NodeId, r.UploadId)
+}
+
+// NodeResult is one item of the response to move, copy and archive requests
+type NodeResult struct {
+	Node
+	TaskId string `json:"taskId,omitempty"`
+}
+
+// UnmarshalJSON keeps the promoted Node.UnmarshalJSON from swallowing TaskId
+func (r *NodeResult) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &r.Node); err != nil {
+		return err
+	}
+	var task struct {
+		TaskId string `json:"taskId"`
+	}
+	if err := json.Unmarshal(b, &task); err != nil {
+		return err
+	}
+	r.TaskId = task.TaskId
+	delete(r.Node.Extra, "taskId")
+	if len(r.Node.Extra) == 0 {
+		r.Node.Extra = nil
+	}
+	return nil
+}
+
+func (r NodeResult) MarshalJSON() ([]byte, error) {
+	node := r.Node
+	if r.TaskId != "" {
+		node.Extra = map[string]json.RawMessage{}
+		for key, value := range r.Node.Extra {
+			node.Extra[key] = value
+		}
+		taskId, _ := json.Marshal(r.TaskId)
+		node.Extra["taskId"] = taskId
+	}
+	return node.MarshalJSON()
+}
+
+type TaskStatus struct {
+	TaskId   string `json:"taskId"`
+	Status   string `json:"status"`
+	Total    int64  `json:"total"`
+	Finished int64  `json:"finished"`
+	Message  string `json:"message,omitempty"`
+}
+
+func (s TaskStatus) String() string {
+	return fmt.Sprintf("TaskStatus{TaskId: %s, Status: %s, Finished: %d/%d}", s.TaskId, s.Status, s.Finished, s.Total)
+}
+
+// Done reports whether the task stopped running, unknown statuses included
+func (s *TaskStatus) Done() bool {
+	return s.Status != TaskRunning
+}
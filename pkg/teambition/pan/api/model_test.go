@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeUnmarshal(t *testing.T) {
+	var node Node
+	err := json.Unmarshal([]byte(`{"kind":"file","name":"a.jpg","nodeId":"1","updated":"2021-03-04T05:06:07.089Z","created":1614834367089,"ext":"jpg","category":"image","starred":true}`), &node)
+	require.NoError(t, err)
+	require.Equal(t, "jpg", node.Ext)
+	require.Equal(t, "image", node.Category)
+	require.Equal(t, time.Date(2021, 3, 4, 5, 6, 7, 89000000, time.UTC), node.UpdatedTime)
+	require.True(t, node.CreatedTime.Equal(node.UpdatedTime))
+	require.Equal(t, "2021-03-04T05:06:07.089Z", node.Created)
+	require.Equal(t, json.RawMessage(`true`), node.Extra["starred"])
+
+	updated, err := node.GetTime()
+	require.NoError(t, err)
+	require.Equal(t, node.UpdatedTime, updated)
+
+	b, err := json.Marshal(&node)
+	require.NoError(t, err)
+	var again Node
+	require.NoError(t, json.Unmarshal(b, &again))
+	require.Equal(t, node, again)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"nodeId":"2","updated":"2021-03-04T05:06:07+08:00"}`), &node))
+	require.Equal(t, "2", node.NodeId)
+	require.Nil(t, node.Extra)
+	require.Equal(t, time.Date(2021, 3, 3, 21, 6, 7, 0, time.UTC), node.UpdatedTime.UTC())
+}
+
+func TestNodeResultUnmarshal(t *testing.T) {
+	nodes, taskId := decodeNodeResults(json.RawMessage(`[{"nodeId":"1","name":"a","taskId":"t"}]`))
+	require.Equal(t, "t", taskId)
+	require.Len(t, nodes, 1)
+	require.Equal(t, "1", nodes[0].NodeId)
+	require.Nil(t, nodes[0].Extra)
+
+	_, taskId = decodeNodeResults(json.RawMessage(`{"taskId":"u"}`))
+	require.Equal(t, "u", taskId)
+}
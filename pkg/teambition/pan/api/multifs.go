@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+)
+
+// MultiFsNodeLimit is how many nodes a MultiFs remembers the account of, the least recently used are forgotten first
+var MultiFsNodeLimit = 100000
+
+// MultiFs serves several accounts under one namespace, "/work/a.txt" is "/a.txt" of the account aliased "work".
+// Nodes must be obtained through the MultiFs so that it knows which account they belong to.
+type MultiFs struct {
+	accounts map[string]Fs
+	// owners maps ownerKey to the path of the node inside its account,
+	// aliases reaching the same drive see the same NodeIds
+	owners   *lru.Cache
+	mutex    sync.RWMutex
+	rootNode Node
+}
+
+type ownerKey struct {
+	alias  string
+	nodeId string
+}
+
+func NewMultiFs(accounts map[string]Fs) *MultiFs {
+	size := MultiFsNodeLimit
+	if size < 1 {
+		size = 1
+	}
+	owners, _ := lru.New(size)
+	m := &MultiFs{
+		accounts: map[string]Fs{},
+		owners:   owners,
+		rootNode: Node{Kind: FolderKind, Name: "Root"},
+	}
+	for alias, fs := range accounts {
+		m.Add(alias, fs)
+	}
+	return m
+}
+
+// NewMultiFsFromConfigs creates an Fs for each config keyed by account alias
+func NewMultiFsFromConfigs(ctx context.Context, configs map[string]*Config) (*MultiFs, error) {
+	m := NewMultiFs(nil)
+	for alias, config := range configs {
+		fs, err := NewFs(ctx, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error creating fs of account "%s"`, alias)
+		}
+		m.Add(alias, fs)
+	}
+	return m, nil
+}
+
+func (m *MultiFs) Add(alias string, fs Fs) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.accounts[alias] = fs
+}
+
+func (m *MultiFs) Aliases() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	aliases := make([]string, 0, len(m.accounts))
+	for alias := range m.accounts {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// route splits "/alias/rest" into the account Fs and "/rest"
+func (m *MultiFs) route(path string) (string, Fs, string, error) {
+	path = normalizePath(path)
+	if path == "/" {
+		return "", nil, path, errors.New("can't operate on root")
+	}
+	alias := path[1:]
+	rest := "/"
+	if i := strings.Index(alias, "/"); i >= 0 {
+		alias, rest = alias[:i], alias[i:]
+	}
+
+	m.mutex.RLock()
+	fs, ok := m.accounts[alias]
+	m.mutex.RUnlock()
+	if !ok {
+		return "", nil, "", errors.Errorf(`unknown account "%s"`, alias)
+	}
+	return alias, fs, rest, nil
+}
+
+func (m *MultiFs) record(alias string, path string, node *Node) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	node.alias = alias
+	m.owners.Add(ownerKey{alias: alias, nodeId: node.NodeId}, normalizePath(path))
+}
+
+// forget drops the nodes of the account at path or under it
+func (m *MultiFs) forget(alias string, path string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, key := range m.owners.Keys() {
+		if value, ok := m.owners.Peek(key); ok && key.(ownerKey).alias == alias && isUnder(value.(string), path) {
+			m.owners.Remove(key)
+		}
+	}
+}
+
+// moveOwners updates the recorded paths of the nodes of the account at oldPath or under it
+func (m *MultiFs) moveOwners(alias string, oldPath string, newPath string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, key := range m.owners.Keys() {
+		if value, ok := m.owners.Peek(key); ok && key.(ownerKey).alias == alias {
+			if path, ok := movedKey(value.(string), oldPath, newPath); ok {
+				m.owners.Add(key, path)
+			}
+		}
+	}
+}
+
+// owner returns the account of a node and its path inside that account
+func (m *MultiFs) owner(node *Node) (string, Fs, string, error) {
+	if node == nil {
+		return "", nil, "", errors.New("empty node")
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, ok := m.owners.Get(ownerKey{alias: node.alias, nodeId: node.NodeId})
+	if !ok {
+		return "", nil, "", errors.Errorf(`unknown node "%s", get it through this MultiFs first`, node)
+	}
+	return node.alias, m.accounts[node.alias], value.(string), nil
+}
+
+func (m *MultiFs) Get(ctx context.Context, path string, kind string) (*Node, error) {
+	if normalizePath(path) == "/" {
+		if kind == FileKind {
+			return nil, errors.New(`"/" is a folder`)
+		}
+		root := m.rootNode
+		return &root, nil
+	}
+
+	alias, fs, rest, err := m.route(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := fs.Get(ctx, rest, kind)
+	if err != nil {
+		return nil, err
+	}
+	if rest == "/" {
+		account := *node
+		account.Name = alias
+		node = &account
+	}
+	m.record(alias, rest, node)
+	return node, nil
+}
+
+func (m *MultiFs) List(ctx context.Context, path string) ([]Node, error) {
+	if normalizePath(path) == "/" {
+		var nodes []Node
+		for _, alias := range m.Aliases() {
+			node, err := m.Get(ctx, "/"+alias, FolderKind)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, *node)
+		}
+		return nodes, nil
+	}
+
+	alias, fs, rest, err := m.route(path)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := fs.List(ctx, rest)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		m.record(alias, joinPath(rest, nodes[i].Name), &nodes[i])
+	}
+	return nodes, nil
+}
+
+func (m *MultiFs) CreateFolder(ctx context.Context, path string) (*Node, error) {
+	alias, fs, rest, err := m.route(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := fs.CreateFolder(ctx, rest)
+	if err != nil {
+		return nil, err
+	}
+	m.record(alias, rest, node)
+	return node, nil
+}
+
+func (m *MultiFs) Rename(ctx context.Context, node *Node, newName string) error {
+	alias, fs, path, err := m.owner(node)
+	if err != nil {
+		return err
+	}
+	if err := fs.Rename(ctx, node, newName); err != nil {
+		return err
+	}
+	m.moveOwners(alias, path, path[:strings.LastIndex(path, "/")]+"/"+newName)
+	return nil
+}
+
+func (m *MultiFs) Move(ctx context.Context, node *Node, parent *Node) (*Node, error) {
+	alias, fs, path, err := m.owner(node)
+	if err != nil {
+		return nil, err
+	}
+	parentAlias, _, parentPath, err := m.owner(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias == parentAlias {
+		moved, err := fs.Move(ctx, node, parent)
+		if err != nil {
+			return nil, err
+		}
+		newPath := joinPath(parentPath, moved.Name)
+		m.moveOwners(alias, path, newPath)
+		m.record(alias, newPath, moved)
+		return moved, nil
+	}
+
+	copied, err := m.Copy(ctx, node, parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.Remove(ctx, node); err != nil {
+		return nil, errors.Wrapf(err, `error removing "%s" after copying it to account "%s"`, node, parentAlias)
+	}
+	m.forget(alias, path)
+	return copied, nil
+}
+
+func (m *MultiFs) Remove(ctx context.Context, node *Node) error {
+	alias, fs, path, err := m.owner(node)
+	if err != nil {
+		return err
+	}
+	if err := fs.Remove(ctx, node); err != nil {
+		return err
+	}
+	m.forget(alias, path)
+	return nil
+}
+
+func (m *MultiFs) Open(ctx context.Context, node *Node, headers map[string]string) (io.ReadCloser, error) {
+	_, fs, _, err := m.owner(node)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(ctx, node, headers)
+}
+
+func (m *MultiFs) CreateFile(ctx context.Context, path string, size int64, in io.Reader, overwrite bool) (*Node, error) {
+	alias, fs, rest, err := m.route(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := fs.CreateFile(ctx, rest, size, in, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	m.record(alias, rest, node)
+	return node, nil
+}
+
+// Copy copies inside an account on the server, across accounts by streaming Open into CreateFile
+func (m *MultiFs) Copy(ctx context.Context, node *Node, parent *Node) (*Node, error) {
+	alias, fs, path, err := m.owner(node)
+	if err != nil {
+		return nil, err
+	}
+	parentAlias, parentFs, parentPath, err := m.owner(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias == parentAlias {
+		copied, err := fs.Copy(ctx, node, parent)
+		if err != nil {
+			return nil, err
+		}
+		if copied.NodeId != "" {
+			m.record(alias, joinPath(parentPath, copied.Name), copied)
+		}
+		return copied, nil
+	}
+
+	if nodeIdFs, ok := fs.(NodeIdFs); ok {
+		// the recorded path is outdated when the account was changed outside this MultiFs
+		if path, err = nodeIdFs.PathOf(ctx, node); err != nil {
+			return nil, errors.Wrapf(err, `error resolving path of "%s"`, node)
+		}
+	}
+	return m.copyAcross(ctx, fs, path, node, parentAlias, parentFs, joinPath(parentPath, node.Name))
+}
+
+func (m *MultiFs) copyAcross(ctx context.Context, src Fs, srcPath string, node *Node, dstAlias string, dst Fs, dstPath string) (*Node, error) {
+	if !node.IsDirectory() {
+		in, err := src.Open(ctx, node, map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+		defer in.Close()
+		copied, err := dst.CreateFile(ctx, dstPath, node.Size, in, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error copying "%s" to account "%s"`, srcPath, dstAlias)
+		}
+		m.record(dstAlias, dstPath, copied)
+		return copied, nil
+	}
+
+	folder, err := dst.CreateFolder(ctx, dstPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error creating "%s" in account "%s"`, dstPath, dstAlias)
+	}
+	m.record(dstAlias, dstPath, folder)
+
+	children, err := src.List(ctx, srcPath)
+	if err != nil {
+		return nil, err
+	}
+	for i := range children {
+		child := &children[i]
+		if _, err := m.copyAcross(ctx, src, joinPath(srcPath, child.Name), child, dstAlias, dst, joinPath(dstPath, child.Name)); err != nil {
+			return nil, err
+		}
+	}
+	return folder, nil
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiFsRenameThenCopyAcross(t *testing.T) {
+	ctx := context.Background()
+	work, home := newMemFs("w"), newMemFs("h")
+	_, err := work.CreateFile(ctx, "/dir/f.txt", 1, bytes.NewBufferString("f"), false)
+	require.NoError(t, err)
+	m := NewMultiFs(map[string]Fs{"work": work, "home": home})
+
+	dir, err := m.Get(ctx, "/work/dir", FolderKind)
+	require.NoError(t, err)
+	_, err = m.List(ctx, "/work/dir")
+	require.NoError(t, err)
+	require.NoError(t, m.Rename(ctx, dir, "renamed"))
+
+	_, _, path, err := m.owner(dir)
+	require.NoError(t, err)
+	require.Equal(t, "/renamed", path)
+
+	root, err := m.Get(ctx, "/home", FolderKind)
+	require.NoError(t, err)
+	_, err = m.Copy(ctx, dir, root)
+	require.NoError(t, err)
+	copied, err := home.Get(ctx, "/dir/f.txt", FileKind)
+	require.NoError(t, err)
+	in, err := home.Open(ctx, copied, nil)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.Equal(t, "f", string(b))
+}
+
+func TestMultiFsForgetsRemoved(t *testing.T) {
+	ctx := context.Background()
+	work := newMemFs("w")
+	_, err := work.CreateFile(ctx, "/dir/f.txt", 1, bytes.NewBufferString("f"), false)
+	require.NoError(t, err)
+	m := NewMultiFs(map[string]Fs{"work": work})
+
+	dir, err := m.Get(ctx, "/work/dir", FolderKind)
+	require.NoError(t, err)
+	_, err = m.List(ctx, "/work/dir")
+	require.NoError(t, err)
+	require.Equal(t, 2, m.owners.Len())
+
+	require.NoError(t, m.Remove(ctx, dir))
+	require.Equal(t, 0, m.owners.Len())
+}
+
+func TestMultiFsAliasesOfSameDrive(t *testing.T) {
+	ctx := context.Background()
+	shared := newMemFs("s")
+	_, err := shared.CreateFile(ctx, "/f.txt", 1, bytes.NewBufferString("f"), false)
+	require.NoError(t, err)
+	_, err = shared.CreateFolder(ctx, "/dir/sub")
+	require.NoError(t, err)
+	m := NewMultiFs(map[string]Fs{"a": shared, "b": shared})
+
+	node, err := m.Get(ctx, "/a/f.txt", FileKind)
+	require.NoError(t, err)
+	sub, err := m.Get(ctx, "/a/dir/sub", FolderKind)
+	require.NoError(t, err)
+	// listing the other alias sees the same NodeIds under other paths
+	listed, err := m.List(ctx, "/b")
+	require.NoError(t, err)
+	require.Equal(t, node.NodeId, listed[1].NodeId)
+
+	alias, _, path, err := m.owner(node)
+	require.NoError(t, err)
+	require.Equal(t, "a", alias)
+	require.Equal(t, "/f.txt", path)
+
+	// a move inside the account keeps the node instead of copying it across accounts
+	moved, err := m.Move(ctx, node, sub)
+	require.NoError(t, err)
+	require.Equal(t, node.NodeId, moved.NodeId)
+	_, _, path, err = m.owner(moved)
+	require.NoError(t, err)
+	require.Equal(t, "/dir/sub/f.txt", path)
+}
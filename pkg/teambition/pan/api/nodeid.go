@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// maxPathDepth bounds the walk of PathOf in case the parents form a cycle
+const maxPathDepth = 1000
+
+// NodeIdFs looks nodes up by NodeId, for callers storing NodeIds rather than paths
+type NodeIdFs interface {
+	GetById(ctx context.Context, nodeId string) (*Node, error)
+	// PathOf walks the parents of node up to the root and returns its path
+	PathOf(ctx context.Context, node *Node) (string, error)
+}
+
+func (teambition *Teambition) GetById(ctx context.Context, nodeId string) (*Node, error) {
+	if nodeId == "" {
+		return nil, errors.New("empty nodeId")
+	}
+	if nodeId == teambition.rootId {
+		root := teambition.rootNode
+		return &root, nil
+	}
+	return teambition.getByNode(ctx, &Node{NodeId: nodeId})
+}
+
+func (teambition *Teambition) PathOf(ctx context.Context, node *Node) (string, error) {
+	if node == nil {
+		return "", errors.New("empty node")
+	}
+
+	// chain holds node and its ancestors missing from the folder cache, deepest first
+	var chain []*Node
+	prefix := ""
+	current := node
+	for {
+		if path, ok := teambition.cachedPath(current); ok {
+			prefix = path
+			break
+		}
+		if len(chain) >= maxPathDepth {
+			return "", errors.Errorf(`"%s" is nested too deep`, node)
+		}
+		if current.Name == "" || current.ParentId == "" {
+			detail, err := teambition.GetById(ctx, current.NodeId)
+			if err != nil {
+				return "", err
+			}
+			current = detail
+			if current.ParentId == "" {
+				return "", errors.Errorf(`"%s" is not under the root of this drive`, node)
+			}
+		}
+		chain = append(chain, current)
+		if path, ok := teambition.cachedPath(&Node{NodeId: current.ParentId}); ok {
+			prefix = path
+			break
+		}
+
+		parent, err := teambition.GetById(ctx, current.ParentId)
+		if err != nil {
+			return "", errors.Wrapf(err, `error getting parent of "%s"`, current)
+		}
+		current = parent
+	}
+
+	path := prefix
+	for i := len(chain) - 1; i >= 0; i-- {
+		path += "/" + chain[i].Name
+		if chain[i].Kind == FolderKind {
+			teambition.folderCache.Put(path, chain[i])
+		}
+	}
+	if path == "" {
+		return "/", nil
+	}
+	return path, nil
+}
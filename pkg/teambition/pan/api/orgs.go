@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// OrgFs enumerates the organizations, spaces and drives reachable by the account,
+// select one with Config.OrgId, Config.SpaceId and Config.DriveId
+type OrgFs interface {
+	ListOrganizations(ctx context.Context) ([]Organization, error)
+	ListSpaces(ctx context.Context, orgId string) ([]Space, error)
+	ListDrives(ctx context.Context, orgId string) ([]Drive, error)
+}
+
+func (teambition *Teambition) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	var orgs []Organization
+	err := teambition.jsonRequest(ctx, "GET", "https://www.teambition.com/api/organizations", nil, &orgs)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing organizations")
+	}
+	return orgs, nil
+}
+
+// https://pan.teambition.com/pan/api/spaces?orgId=&memberId=
+func (teambition *Teambition) ListSpaces(ctx context.Context, orgId string) ([]Space, error) {
+	var spaces []Space
+	err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf("https://pan.teambition.com/pan/api/spaces?orgId=%s&memberId=%s", orgId, teambition.memberId), nil, &spaces)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing spaces of "%s"`, orgId)
+	}
+	return spaces, nil
+}
+
+func (teambition *Teambition) ListDrives(ctx context.Context, orgId string) ([]Drive, error) {
+	drive, err := teambition.getDrive(ctx, orgId)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing drives of "%s"`, orgId)
+	}
+	if drive.Data.DriveId == "" {
+		return nil, nil
+	}
+	return []Drive{*drive}, nil
+}
+
+// selectSpace returns the space with spaceId, or the first one when spaceId is empty
+func selectSpace(spaces []Space, spaceId string) (*Space, error) {
+	if len(spaces) < 1 {
+		return nil, errors.New("empty spaces")
+	}
+	if spaceId == "" {
+		return &spaces[0], nil
+	}
+	for i := range spaces {
+		if spaces[i].SpaceId == spaceId || spaces[i].RootId == spaceId {
+			return &spaces[i], nil
+		}
+	}
+	return nil, errors.Errorf(`can't find space "%s"`, spaceId)
+}
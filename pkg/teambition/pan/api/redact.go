@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MaxEchoedBody is the maximum number of response bytes included in error messages
+var MaxEchoedBody = 512
+
+const redacted = "REDACTED"
+
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>\\]+`)
+
+// redactCookie keeps only the names of the cookies
+func redactCookie(cookie string) string {
+	cookies := parseCookie(cookie)
+	if len(cookies) < 1 {
+		return ""
+	}
+	parts := make([]string, len(cookies))
+	for i, c := range cookies {
+		parts[i] = c.Name + "=" + redacted
+	}
+	return strings.Join(parts, "; ")
+}
+
+// redactUrl replaces the query values of signed urls like DownloadUrl and UploadUrl
+func redactUrl(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	query := u.Query()
+	for key := range query {
+		query[key] = []string{redacted}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactBody makes a response body safe for error messages: urls are redacted, cookie values removed and the length capped
+func redactBody(b []byte, cookie string) string {
+	s := urlPattern.ReplaceAllStringFunc(string(b), redactUrl)
+	for _, c := range parseCookie(cookie) {
+		if len(c.Value) > 3 {
+			s = strings.Replace(s, c.Value, redacted, -1)
+		}
+	}
+	if MaxEchoedBody >= 0 && len(s) > MaxEchoedBody {
+		s = s[:MaxEchoedBody] + "...(truncated)"
+	}
+	return s
+}
+
+// redactError hides the url of errors returned by http.Client, which may carry signatures
+func redactError(err error) error {
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		urlError.URL = redactUrl(urlError.URL)
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	cookie := "TEAMBITION_SESSIONID=secret-session; TEAMBITION_SESSIONID.sig=secret-sig"
+	s := fmt.Sprint(Config{Cookie: cookie})
+	require.NotContains(t, s, "secret")
+	require.Contains(t, s, "TEAMBITION_SESSIONID")
+
+	require.Equal(t, "https://example.com/file?Expires=REDACTED&Signature=REDACTED",
+		redactUrl("https://example.com/file?Signature=abc&Expires=123"))
+
+	body := []byte(`{"downloadUrl":"https://example.com/file?Signature=abc","echo":"secret-session"}` + strings.Repeat(" ", 1024))
+	s = redactBody(body, cookie)
+	require.NotContains(t, s, "abc")
+	require.NotContains(t, s, "secret")
+	require.True(t, len(s) < 600)
+}
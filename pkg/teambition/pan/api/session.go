@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrSessionExpired = errors.New("session expired, please login again")
+
+// CookieDomain is the domain session cookies are sent to
+const CookieDomain = "teambition.com"
+
+var sessionUrls = []*url.URL{
+	{Scheme: "https", Host: "www.teambition.com", Path: "/"},
+	{Scheme: "https", Host: "pan.teambition.com", Path: "/"},
+}
+
+// CookieStore persists the session cookie after the server refreshed it
+type CookieStore interface {
+	SaveCookie(cookie string) error
+}
+
+// CookieStoreFunc adapts a function to CookieStore
+type CookieStoreFunc func(cookie string) error
+
+func (f CookieStoreFunc) SaveCookie(cookie string) error {
+	return f(cookie)
+}
+
+// FileCookieStore writes the cookie to a file readable only by the current user
+type FileCookieStore struct {
+	Path string
+}
+
+func (s FileCookieStore) SaveCookie(cookie string) error {
+	if err := ioutil.WriteFile(s.Path, []byte(cookie), 0600); err != nil {
+		return errors.Wrapf(err, `error writing cookie to "%s"`, s.Path)
+	}
+	return nil
+}
+
+// parseCookie parses a "Cookie" header value like "a=1; b=2"
+func parseCookie(cookie string) []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, part := range strings.Split(cookie, ";") {
+		part = strings.TrimSpace(part)
+		i := strings.Index(part, "=")
+		if i < 1 {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:   strings.TrimSpace(part[:i]),
+			Value:  strings.TrimSpace(part[i+1:]),
+			Domain: CookieDomain,
+			Path:   "/",
+		})
+	}
+	return cookies
+}
+
+// formatCookie formats cookies as a "Cookie" header value, sorted by name
+func formatCookie(cookies []*http.Cookie) string {
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Name < cookies[j].Name
+	})
+	parts := make([]string, len(cookies))
+	for i, c := range cookies {
+		parts[i] = c.Name + "=" + c.Value
+	}
+	return strings.Join(parts, "; ")
+}
+
+func isLoginUrl(u *url.URL) bool {
+	return strings.HasPrefix(u.Host, "account.") || strings.HasPrefix(u.Path, "/login")
+}
+
+func newSessionClient(cookie string) (*http.Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating cookie jar")
+	}
+	jar.SetCookies(sessionUrls[0], parseCookie(cookie))
+
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if isLoginUrl(req.URL) {
+				return ErrSessionExpired
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}, nil
+}
+
+// sessionCookie returns the current session cookies of the jar
+func (teambition *Teambition) sessionCookie() string {
+	seen := map[string]bool{}
+	var cookies []*http.Cookie
+	for _, u := range sessionUrls {
+		for _, c := range teambition.httpClient.Jar.Cookies(u) {
+			if !seen[c.Name] {
+				seen[c.Name] = true
+				cookies = append(cookies, c)
+			}
+		}
+	}
+	return formatCookie(cookies)
+}
+
+// checkSession saves refreshed cookies and turns logged out responses into ErrSessionExpired
+func (teambition *Teambition) checkSession(res *http.Response) error {
+	if res.StatusCode == http.StatusUnauthorized || isLoginUrl(res.Request.URL) {
+		return ErrSessionExpired
+	}
+
+	if len(res.Header.Values("Set-Cookie")) > 0 && teambition.httpClient.Jar != nil {
+		teambition.cookieMutex.Lock()
+		defer teambition.cookieMutex.Unlock()
+
+		cookie := teambition.sessionCookie()
+		if cookie == teambition.config.Cookie {
+			return nil
+		}
+		teambition.config.Cookie = cookie
+		if teambition.config.CookieStore != nil {
+			if err := teambition.config.CookieStore.SaveCookie(cookie); err != nil {
+				return errors.Wrap(err, "error saving refreshed cookie")
+			}
+		}
+	}
+	return nil
+}
+
+// refreshCredentials asks the credential provider for a new cookie, it reports whether the session cookie changed
+func (teambition *Teambition) refreshCredentials(ctx context.Context) bool {
+	if teambition.config.Credentials == nil || teambition.httpClient.Jar == nil {
+		return false
+	}
+	cookie, err := teambition.config.Credentials.Cookie(ctx)
+	if err != nil {
+		return false
+	}
+
+	teambition.cookieMutex.Lock()
+	defer teambition.cookieMutex.Unlock()
+	if cookie == teambition.config.Cookie {
+		return false
+	}
+	teambition.httpClient.Jar.SetCookies(sessionUrls[0], parseCookie(cookie))
+	teambition.config.Cookie = cookie
+	return true
+}
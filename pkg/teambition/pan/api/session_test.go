@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieStore(t *testing.T) {
+	client, err := newSessionClient("b=2; a=1")
+	require.NoError(t, err)
+	var saved string
+	teambition := &Teambition{
+		httpClient: client,
+		config: Config{
+			Cookie: "b=2; a=1",
+			CookieStore: CookieStoreFunc(func(cookie string) error {
+				saved = cookie
+				return nil
+			}),
+		},
+	}
+
+	client.Jar.SetCookies(sessionUrls[1], []*http.Cookie{{Name: "a", Value: "3", Domain: CookieDomain}})
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Set-Cookie": []string{"a=3"}},
+		Request:    &http.Request{URL: sessionUrls[1]},
+	}
+	require.NoError(t, teambition.checkSession(res))
+	require.Equal(t, "a=3; b=2", saved)
+
+	res.StatusCode = http.StatusUnauthorized
+	require.True(t, errors.Is(teambition.checkSession(res), ErrSessionExpired))
+}
+
+func TestCookieRefreshWhileFormatting(t *testing.T) {
+	client, err := newSessionClient("a=1")
+	require.NoError(t, err)
+	teambition := &Teambition{
+		httpClient: client,
+		config: Config{
+			Cookie:      "a=1",
+			Credentials: EnvCredentials{Name: "TEAMBITION_TEST_COOKIE"},
+		},
+	}
+	require.NoError(t, os.Setenv("TEAMBITION_TEST_COOKIE", "a=2"))
+	defer os.Unsetenv("TEAMBITION_TEST_COOKIE")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		teambition.refreshCredentials(context.Background())
+	}()
+	_ = teambition.String()
+	<-done
+	require.Equal(t, "a=2", teambition.cookie())
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+// flightCall is a call in flight or completed of flightGroup
+type flightCall struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	shared  bool
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flightGroup collapses concurrent calls with the same key into one, the waiters share its result
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+// Do runs fn unless a call with the same key is in flight, in which case it waits for that call.
+// fn gets a context which is not canceled before every waiting caller gave up, so that one caller
+// canceling doesn't fail the others. shared reports whether the result was given to more than one caller.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	call, ok := g.calls[key]
+	if ok {
+		call.shared = true
+		call.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.Background())
+		call = &flightCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			value, err := fn(callCtx)
+			g.mutex.Lock()
+			call.value, call.err = value, err
+			g.forget(key, call)
+			g.mutex.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		return call.value, call.err, call.shared
+	case <-ctx.Done():
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			g.forget(key, call)
+		}
+		return nil, ctx.Err(), false
+	}
+}
+
+// forget removes call so that later calls with key start a new one, g.mutex must be held
+func (g *flightGroup) forget(key string, call *flightCall) {
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
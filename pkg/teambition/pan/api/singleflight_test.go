@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls int32
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	results := make([]interface{}, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, _, _ := g.Do(context.Background(), "a", func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "x", nil
+			})
+			results[i] = value
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fn should run once, but ran %d times", calls)
+	}
+	for _, value := range results {
+		if value != "x" {
+			t.Errorf(`every caller should get "%s", but get "%v"`, "x", value)
+		}
+	}
+}
+
+func TestFlightGroupLeaderCanceled(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return "x", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err, _ := g.Do(leaderCtx, "a", fn)
+		leader <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	waiter := make(chan interface{})
+	go func() {
+		value, _, _ := g.Do(context.Background(), "a", fn)
+		waiter <- value
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("leader should be canceled, but get %v", err)
+	}
+	close(release)
+	if value := <-waiter; value != "x" {
+		t.Errorf(`waiter should get "%s", but get "%v"`, "x", value)
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const tempFilePrefix = ".teambition-"
+
+type SyncDownOptions struct {
+	// Prune removes local files and folders which no longer exist remotely
+	Prune bool
+}
+
+// SyncDown mirrors the remote folder into the local directory, downloading new or changed files
+func SyncDown(ctx context.Context, fs Fs, remote string, local string, options *SyncDownOptions) error {
+	if options == nil {
+		options = &SyncDownOptions{}
+	}
+	remote = normalizePath(remote)
+	return syncDownFolder(ctx, fs, remote, local, options)
+}
+
+func joinPath(parent string, name string) string {
+	if parent == "/" {
+		return parent + name
+	}
+	return parent + "/" + name
+}
+
+// checkLocalName fails on remote names which would leave the local folder they are joined to
+func checkLocalName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') || strings.ContainsRune(name, os.PathSeparator) {
+		return errors.Errorf(`invalid local name "%s"`, name)
+	}
+	return nil
+}
+
+func syncDownFolder(ctx context.Context, fs Fs, remote string, local string, options *SyncDownOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return errors.Wrapf(err, `error creating local folder "%s"`, local)
+	}
+
+	nodes, err := fs.List(ctx, remote)
+	if err != nil {
+		return errors.Wrapf(err, `error listing "%s"`, remote)
+	}
+
+	seen := make(map[string]bool, len(nodes))
+	for i := range nodes {
+		node := &nodes[i]
+		if err := checkLocalName(node.Name); err != nil {
+			return errors.Wrapf(err, `error syncing "%s"`, remote)
+		}
+		seen[node.Name] = true
+		localPath := filepath.Join(local, node.Name)
+		info, statErr := os.Lstat(localPath)
+		if statErr == nil && info.IsDir() != node.IsDirectory() {
+			if err := os.RemoveAll(localPath); err != nil {
+				return errors.Wrapf(err, `error removing "%s"`, localPath)
+			}
+			statErr = os.ErrNotExist
+		}
+
+		if node.IsDirectory() {
+			if err := syncDownFolder(ctx, fs, joinPath(remote, node.Name), localPath, options); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if statErr == nil && !fileChanged(info, node) {
+			continue
+		}
+		if err := downloadFile(ctx, fs, node, localPath); err != nil {
+			return errors.Wrapf(err, `error downloading "%s"`, joinPath(remote, node.Name))
+		}
+	}
+
+	if options.Prune {
+		infos, err := ioutil.ReadDir(local)
+		if err != nil {
+			return errors.Wrapf(err, `error reading local folder "%s"`, local)
+		}
+		for _, info := range infos {
+			if seen[info.Name()] || strings.HasPrefix(info.Name(), tempFilePrefix) {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(local, info.Name())); err != nil {
+				return errors.Wrapf(err, `error pruning "%s"`, info.Name())
+			}
+		}
+	}
+
+	return nil
+}
+
+// fileChanged reports whether the local file differs from the remote node, by size and modification time
+func fileChanged(info os.FileInfo, node *Node) bool {
+	if info.Size() != node.Size {
+		return true
+	}
+	t, err := node.GetTime()
+	if err != nil {
+		return false
+	}
+	return !info.ModTime().Truncate(time.Second).Equal(t.Truncate(time.Second))
+}
+
+// downloadFile writes the node content to a temporary file next to localPath, then renames it into place
+func downloadFile(ctx context.Context, fs Fs, node *Node, localPath string) error {
+	in, err := fs.Open(ctx, node, map[string]string{})
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(localPath), tempFilePrefix+"*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "error creating temp file")
+	}
+	tempPath := out.Name()
+	defer os.Remove(tempPath)
+
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "error writing temp file")
+	}
+	if n != node.Size {
+		return errors.Errorf(`downloaded %d bytes of "%s", expected %d`, n, node, node.Size)
+	}
+
+	if t, err := node.GetTime(); err == nil {
+		if err := os.Chtimes(tempPath, t, t); err != nil {
+			return errors.Wrap(err, "error setting modification time")
+		}
+	}
+
+	if err := os.Rename(tempPath, localPath); err != nil {
+		return errors.Wrapf(err, `error renaming temp file to "%s"`, localPath)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadFileTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	fs := newMemFs("")
+	node, err := fs.CreateFile(ctx, "/a.txt", 6, bytes.NewBufferString("abcdef"), false)
+	require.NoError(t, err)
+	fs.truncated[node.NodeId] = true
+
+	localPath := filepath.Join(dir, "a.txt")
+	require.Error(t, downloadFile(ctx, fs, node, localPath))
+	_, err = os.Stat(localPath)
+	require.True(t, os.IsNotExist(err))
+
+	fs.truncated[node.NodeId] = false
+	require.NoError(t, downloadFile(ctx, fs, node, localPath))
+	b, err := ioutil.ReadFile(localPath)
+	require.NoError(t, err)
+	require.Equal(t, "abcdef", string(b))
+}
+
+// listConflicts returns the names containing "(conflict" found locally and remotely
+func listConflicts(t *testing.T, fs *memFs, local string) []string {
+	var conflicts []string
+	err := filepath.Walk(local, func(p string, info os.FileInfo, err error) error {
+		if err == nil && strings.Contains(info.Name(), "(conflict") {
+			conflicts = append(conflicts, p)
+		}
+		return err
+	})
+	require.NoError(t, err)
+	for _, node := range fs.nodes {
+		if strings.Contains(node.Name, "(conflict") {
+			conflicts = append(conflicts, node.Name)
+		}
+	}
+	return conflicts
+}
+
+func TestSyncAdoptsMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	fs := newMemFs("")
+	_, err = fs.CreateFile(ctx, "/sync/a.txt", 1, bytes.NewBufferString("a"), false)
+	require.NoError(t, err)
+	_, err = fs.CreateFile(ctx, "/sync/sub/b.txt", 2, bytes.NewBufferString("bb"), false)
+	require.NoError(t, err)
+
+	local := filepath.Join(dir, "sync")
+	require.NoError(t, SyncDown(ctx, fs, "/sync", local, nil))
+	require.NoError(t, Sync(ctx, fs, "/sync", local, nil))
+	require.Empty(t, listConflicts(t, fs, local))
+
+	state, err := LoadSyncState(filepath.Join(local, defaultSyncStateName))
+	require.NoError(t, err)
+	require.Contains(t, state.Entries, "a.txt")
+	require.Contains(t, state.Entries, "sub/b.txt")
+
+	// a real conflict still keeps both versions
+	require.NoError(t, ioutil.WriteFile(filepath.Join(local, "a.txt"), []byte("local"), 0644))
+	_, err = fs.CreateFile(ctx, "/sync/a.txt", 6, bytes.NewBufferString("remote"), true)
+	require.NoError(t, err)
+	require.NoError(t, Sync(ctx, fs, "/sync", local, nil))
+	require.Len(t, listConflicts(t, fs, local), 2)
+	b, err := ioutil.ReadFile(filepath.Join(local, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "remote", string(b))
+}
+
+func TestSyncMovesAndDeletes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	fs := newMemFs("")
+	node, err := fs.CreateFile(ctx, "/sync/a.txt", 1, bytes.NewBufferString("a"), false)
+	require.NoError(t, err)
+	_, err = fs.CreateFile(ctx, "/sync/b.txt", 1, bytes.NewBufferString("b"), false)
+	require.NoError(t, err)
+	local := filepath.Join(dir, "sync")
+	require.NoError(t, Sync(ctx, fs, "/sync", local, nil))
+
+	// renamed remotely, deleted locally
+	require.NoError(t, fs.Rename(ctx, node, "c.txt"))
+	require.NoError(t, os.Remove(filepath.Join(local, "b.txt")))
+	require.NoError(t, Sync(ctx, fs, "/sync", local, nil))
+
+	_, err = os.Stat(filepath.Join(local, "c.txt"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(local, "a.txt"))
+	require.True(t, os.IsNotExist(err))
+	_, err = fs.Get(ctx, "/sync/b.txt", FileKind)
+	require.Error(t, err)
+	require.Empty(t, listConflicts(t, fs, local))
+}
+
+func TestSyncDownInvalidName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	fs := newMemFs("")
+	node, err := fs.CreateFolder(ctx, "/sync/a")
+	require.NoError(t, err)
+	_, err = fs.CreateFile(ctx, "/sync/b.txt", 1, bytes.NewBufferString("b"), false)
+	require.NoError(t, err)
+	local := filepath.Join(dir, "parent", "sync")
+	require.NoError(t, SyncDown(ctx, fs, "/sync", local, nil))
+
+	// a folder named ".." would replace the parent of the local folder
+	fs.nodes[node.NodeId].Name = ".."
+	require.Error(t, SyncDown(ctx, fs, "/sync", local, &SyncDownOptions{Prune: true}))
+	_, err = os.Stat(filepath.Join(local, "b.txt"))
+	require.NoError(t, err)
+	_, err = os.Stat(local)
+	require.NoError(t, err)
+}
+
+func TestSyncInvalidName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	fs := newMemFs("")
+	node, err := fs.CreateFile(ctx, "/sync/a.txt", 1, bytes.NewBufferString("a"), false)
+	require.NoError(t, err)
+	local := filepath.Join(dir, "parent", "sync")
+	require.NoError(t, Sync(ctx, fs, "/sync", local, nil))
+
+	// moving "a.txt" to "../a.txt" would leave the local folder
+	fs.nodes[node.NodeId].Name = "../a.txt"
+	require.Error(t, Sync(ctx, fs, "/sync", local, nil))
+	_, err = os.Stat(filepath.Join(local, "a.txt"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "parent", "a.txt"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const TaskRunning = "running"
+const TaskSucceeded = "succeed"
+const TaskFailed = "failed"
+
+// TaskPollInterval is the first delay between two status polls, doubled after each poll up to TaskMaxPollInterval
+var TaskPollInterval = 500 * time.Millisecond
+var TaskMaxPollInterval = 10 * time.Second
+
+// AsyncFs starts copy, move and remove operations which the server may finish in the background
+type AsyncFs interface {
+	MoveAsync(ctx context.Context, node *Node, parent *Node) (*Task, error)
+	CopyAsync(ctx context.Context, node *Node, parent *Node) (*Task, error)
+	RemoveAsync(ctx context.Context, node *Node) (*Task, error)
+}
+
+// Task is a handle of a server side operation, Id is empty when the operation already completed.
+// The Node of a copy made in the background has no NodeId until the task succeeded.
+type Task struct {
+	Id         string
+	Node       *Node
+	teambition *Teambition
+	// copyOf, parent and existing locate the copy once the task succeeded
+	copyOf   *Node
+	parent   *Node
+	existing map[string]bool
+}
+
+func (task *Task) String() string {
+	return fmt.Sprintf("Task{Id: %s, Node: %s}", task.Id, task.Node)
+}
+
+func (teambition *Teambition) newTask(taskId string, node *Node) *Task {
+	return &Task{Id: taskId, Node: node, teambition: teambition}
+}
+
+// decodeNodeResults parses either a list of NodeResult or a single object carrying a taskId
+func decodeNodeResults(raw json.RawMessage) ([]Node, string) {
+	var results []NodeResult
+	if err := json.Unmarshal(raw, &results); err == nil {
+		taskId := ""
+		nodes := make([]Node, len(results))
+		for i, result := range results {
+			nodes[i] = result.Node
+			if taskId == "" {
+				taskId = result.TaskId
+			}
+		}
+		return nodes, taskId
+	}
+
+	var result NodeResult
+	if err := json.Unmarshal(raw, &result); err == nil {
+		return nil, result.TaskId
+	}
+	return nil, ""
+}
+
+// https://pan.teambition.com/pan/api/tasks/{taskId}?orgId=&driveId=
+func (task *Task) Status(ctx context.Context) (*TaskStatus, error) {
+	if task.Id == "" {
+		return &TaskStatus{Status: TaskSucceeded}, nil
+	}
+
+	teambition := task.teambition
+	var status TaskStatus
+	err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf("https://pan.teambition.com/pan/api/tasks/%s?orgId=%s&driveId=%s", task.Id, teambition.orgId, teambition.driveId), nil, &status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting status of %s", task)
+	}
+	if status.TaskId == "" {
+		status.TaskId = task.Id
+	}
+	return &status, nil
+}
+
+// Poll polls the task status with exponential backoff until it is done, calling progress after each poll
+func (task *Task) Poll(ctx context.Context, progress func(status *TaskStatus)) error {
+	interval := TaskPollInterval
+	for {
+		status, err := task.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(status)
+		}
+		if status.Done() {
+			if task.Id != "" && task.Node != nil {
+				// the listings cached while the task was running miss its result
+				task.teambition.invalidateListings(task.Node.ParentId)
+			}
+			switch status.Status {
+			case TaskSucceeded:
+			case TaskFailed:
+				return errors.Errorf("%s failed: %s", task, status.Message)
+			default:
+				return errors.Errorf(`%s ended with unknown status "%s": %s`, task, status.Status, status.Message)
+			}
+			return task.resolveCopy(ctx)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > TaskMaxPollInterval {
+			interval = TaskMaxPollInterval
+		}
+	}
+}
+
+// resolveCopy sets the Node of a finished background copy
+func (task *Task) resolveCopy(ctx context.Context) error {
+	if task.copyOf == nil || task.Node == nil || task.Node.NodeId != "" {
+		return nil
+	}
+	teambition := task.teambition
+	copies, err := teambition.findCopies(ctx, []*Node{task.copyOf}, task.parent, task.existing)
+	if err != nil {
+		return err
+	}
+	task.Node = &copies[0]
+	teambition.cacheCopiedFolder(task.Node, task.parent)
+	return nil
+}
+
+// Wait blocks until the task is done
+func (task *Task) Wait(ctx context.Context) error {
+	return task.Poll(ctx, nil)
+}
+
+func (teambition *Teambition) MoveAsync(ctx context.Context, node *Node, parent *Node) (*Task, error) {
+	if err := teambition.checkRoot(node); err != nil {
+		return nil, err
+	}
+
+	if parent == nil {
+		return nil, errors.New("parent node is empty")
+	}
+	moved, taskId, err := teambition.moveNodes(ctx, []*Node{node}, parent)
+	if err != nil {
+		return nil, err
+	}
+	return teambition.newTask(taskId, &moved[0]), nil
+}
+
+func (teambition *Teambition) CopyAsync(ctx context.Context, node *Node, parent *Node) (*Task, error) {
+	if err := teambition.checkRoot(node); err != nil {
+		return nil, err
+	}
+
+	if parent == nil {
+		return nil, errors.New("parent node is empty")
+	}
+	unlock := teambition.copyLocks.Lock(parent.NodeId)
+	defer unlock()
+	existing, err := teambition.childIds(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	copied, taskId, err := teambition.copyNodes(ctx, []*Node{node}, parent, existing)
+	if err != nil {
+		return nil, err
+	}
+	task := teambition.newTask(taskId, &copied[0])
+	task.copyOf, task.parent, task.existing = node, parent, existing
+	return task, nil
+}
+
+func (teambition *Teambition) RemoveAsync(ctx context.Context, node *Node) (*Task, error) {
+	if err := teambition.checkRoot(node); err != nil {
+		return nil, err
+	}
+
+	taskId, err := teambition.removeNodes(ctx, []*Node{node})
+	if err != nil {
+		return nil, err
+	}
+	return teambition.newTask(taskId, node), nil
+}
@@ -8,9 +8,11 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -22,36 +24,96 @@ type Fs interface {
 	List(ctx context.Context, path string) ([]Node, error)
 	CreateFolder(ctx context.Context, path string) (*Node, error)
 	Rename(ctx context.Context, node *Node, newName string) error
-	Move(ctx context.Context, node *Node, parent *Node) error
+	Move(ctx context.Context, node *Node, parent *Node) (*Node, error)
 	Remove(ctx context.Context, node *Node) error
 	Open(ctx context.Context, node *Node, headers map[string]string) (io.ReadCloser, error)
 	CreateFile(ctx context.Context, path string, size int64, in io.Reader, overwrite bool) (*Node, error)
-	Copy(ctx context.Context, node *Node, parent *Node) error
+	Copy(ctx context.Context, node *Node, parent *Node) (*Node, error)
 }
 
 type Config struct {
 	Cookie string
+	// OrgId, SpaceId and DriveId select what the Fs operates on, the personal organization,
+	// its first space and its drive are used when empty
+	OrgId   string
+	SpaceId string
+	DriveId string
+	// FolderCacheSize is how many folder paths are cached, DefaultFolderCacheSize when 0
+	FolderCacheSize int
+	// FolderCacheTTL is how long a resolved folder path is trusted before it is resolved again, forever when 0
+	FolderCacheTTL time.Duration
+	// StaleWhileRevalidate serves stale folder paths while resolving them again in the background
+	StaleWhileRevalidate bool
+	// ListCacheTTL is how long folder listings are cached, DefaultListCacheTTL when 0
+	ListCacheTTL time.Duration
+	// DisableListCache makes every lookup list the folder again
+	DisableListCache bool
+	// NegativeCacheTTL is how long a name missing from a folder is remembered, DefaultNegativeCacheTTL when 0
+	NegativeCacheTTL time.Duration
+	// DisableNegativeCache makes every lookup of a missing name list the folder again
+	DisableNegativeCache bool
+	// CachePath is a file the folder and listing caches are loaded from and saved to by Close, optional.
+	// Folder paths saved longer ago than DiskCacheMaxAge or FolderCacheTTL are not loaded
+	CachePath string
+	// RefreshCache ignores the entries saved in CachePath
+	RefreshCache bool
+	// CheckQuota makes CreateFile fail fast with ErrQuotaExceeded when the file doesn't fit in the drive
+	CheckQuota bool
+	// CookieStore receives the cookie whenever the server refreshes the session, optional
+	CookieStore CookieStore
+	// Credentials supplies the cookie when Cookie is empty and after the session expired, optional
+	Credentials CredentialProvider
 }
 
 func (config Config) String() string {
-	return fmt.Sprintf("Config{Cookie: %s}", config.Cookie)
+	return fmt.Sprintf("Config{Cookie: %s}", redactCookie(config.Cookie))
 }
 
+const DefaultFolderCacheSize = 256
+
+const DefaultListCacheTTL = 30 * time.Second
+
+const DefaultNegativeCacheTTL = 5 * time.Second
+
 type Teambition struct {
-	folderCache FolderCache
-	config      Config
-	orgId       string
-	memberId    string
-	rootId      string
-	rootNode    Node
-	driveId     string
-	ApiBaseUrl  string
-	httpClient  *http.Client
-	mutex       sync.Mutex
+	folderCache   FolderCache
+	listingCache  ListingCache
+	negativeCache NegativeCache
+	diskCache     *DiskCache
+	config        Config
+	personal      Personal
+	orgId         string
+	memberId      string
+	rootId        string
+	rootNode      Node
+	driveId       string
+	ApiBaseUrl    string
+	httpClient    *http.Client
+	cookieMutex   sync.Mutex
+	// listingMutex guards listingGeneration, which invalidateListings bumps so that the listings and missing names
+	// fetched before an invalidation aren't cached after it
+	listingMutex      sync.Mutex
+	listingGeneration uint64
+	// folderLocks serializes the creation of each folder path
+	folderLocks keyedMutex
+	// copyLocks serializes the copies into each parent NodeId, whose new children tell the copies apart
+	copyLocks keyedMutex
+	// revalidating holds the stale folder paths being resolved in the background
+	revalidating sync.Map
+	// listFlight and resolveFlight collapse concurrent listings of a NodeId and resolutions of a path
+	listFlight    flightGroup
+	resolveFlight flightGroup
 }
 
 func (teambition *Teambition) String() string {
-	return fmt.Sprintf("Teambition{orgId: %s, memberId: %s}", teambition.orgId, teambition.memberId)
+	return fmt.Sprintf("Teambition{orgId: %s, memberId: %s, config: %s}", teambition.orgId, teambition.memberId, Config{Cookie: teambition.cookie()})
+}
+
+// cookie returns the current session cookie, which checkSession and refreshCredentials replace concurrently
+func (teambition *Teambition) cookie() string {
+	teambition.cookieMutex.Lock()
+	defer teambition.cookieMutex.Unlock()
+	return teambition.config.Cookie
 }
 
 func (teambition *Teambition) request(ctx context.Context, method, url string, headers map[string]string, body io.Reader) (*http.Response, error) {
@@ -66,15 +128,22 @@ func (teambition *Teambition) request(ctx context.Context, method, url string, h
 
 	res, err2 := teambition.httpClient.Do(req)
 	if err2 != nil {
-		return nil, errors.WithStack(err2)
+		return nil, errors.WithStack(redactError(err2))
 	}
 	return res, nil
 }
 
 func (teambition *Teambition) jsonRequest(ctx context.Context, method, url string, requestModel interface{}, responseModel interface{}) error {
+	err := teambition.doJsonRequest(ctx, method, url, requestModel, responseModel)
+	if errors.Is(err, ErrSessionExpired) && teambition.refreshCredentials(ctx) {
+		err = teambition.doJsonRequest(ctx, method, url, requestModel, responseModel)
+	}
+	return err
+}
+
+func (teambition *Teambition) doJsonRequest(ctx context.Context, method, url string, requestModel interface{}, responseModel interface{}) error {
 	headers := map[string]string{
 		"Content-Type": "application/json",
-		"Cookie":       teambition.config.Cookie,
 	}
 
 	var body io.Reader
@@ -92,14 +161,27 @@ func (teambition *Teambition) jsonRequest(ctx context.Context, method, url strin
 	}
 	defer res.Body.Close()
 
+	if err := teambition.checkSession(res); err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return errors.Errorf(`error requesting "%s": %s, response: %s`, redactUrl(url), res.Status, redactBody(b, teambition.cookie()))
+	}
+
 	if responseModel != nil {
 		b, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return errors.Wrap(err, `error reading res.Body`)
 		}
+		// move, copy and archive may answer nothing when they complete at once
+		if _, isRaw := responseModel.(*json.RawMessage); isRaw && len(bytes.TrimSpace(b)) == 0 {
+			return nil
+		}
 		err = json.Unmarshal(b, &responseModel)
 		if err != nil {
-			return errors.Wrapf(err, "error parsing responseModel, response: %s", string(b))
+			return errors.Wrapf(err, "error parsing responseModel, response: %s", redactBody(b, teambition.cookie()))
 		}
 	}
 
@@ -107,18 +189,59 @@ func (teambition *Teambition) jsonRequest(ctx context.Context, method, url strin
 }
 
 func NewFs(ctx context.Context, config *Config) (Fs, error) {
-	cache, cerr := NewCache(256)
+	cacheSize := config.FolderCacheSize
+	if cacheSize == 0 {
+		cacheSize = DefaultFolderCacheSize
+	}
+	cache, cerr := NewCacheWithTTL(cacheSize, config.FolderCacheTTL)
 	if cerr != nil {
 		return nil, errors.Wrap(cerr, "error creating cache")
 	}
 
-	client := &http.Client{}
+	cookie := config.Cookie
+	if cookie == "" && config.Credentials != nil {
+		c, err := config.Credentials.Cookie(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting credentials")
+		}
+		cookie = c
+	}
+
+	client, err := newSessionClient(cookie)
+	if err != nil {
+		return nil, err
+	}
 	teambition := &Teambition{
 		config:      *config,
 		ApiBaseUrl:  BaseUrl,
 		httpClient:  client,
 		folderCache: cache,
 	}
+	teambition.config.Cookie = cookie
+
+	listTTL := config.ListCacheTTL
+	if listTTL == 0 {
+		listTTL = DefaultListCacheTTL
+	}
+	if !config.DisableListCache {
+		listingCache, err := NewListingCache(256, listTTL)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating listing cache")
+		}
+		teambition.listingCache = listingCache
+	}
+
+	if !config.DisableNegativeCache {
+		ttl := config.NegativeCacheTTL
+		if ttl == 0 {
+			ttl = DefaultNegativeCacheTTL
+		}
+		negativeCache, err := NewNegativeCache(256, ttl)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating negative cache")
+		}
+		teambition.negativeCache = negativeCache
+	}
 
 	// get orgId, memberId
 	{
@@ -128,21 +251,25 @@ func NewFs(ctx context.Context, config *Config) (Fs, error) {
 			return nil, errors.Wrap(err, "error getting orgId, memberId")
 		}
 
+		teambition.personal = personal
 		teambition.orgId = personal.Id
 		teambition.memberId = personal.CreatorId
+		if config.OrgId != "" {
+			teambition.orgId = config.OrgId
+		}
 	}
 
 	// get root parentId
 	{
-		var spaces []Space
-		err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf("https://pan.teambition.com/pan/api/spaces?orgId=%s&memberId=%s", teambition.orgId, teambition.memberId), nil, &spaces)
+		spaces, err := teambition.ListSpaces(ctx, teambition.orgId)
 		if err != nil {
 			return nil, errors.Wrap(err, "error getting root parentId")
 		}
-		if len(spaces) < 1 {
-			return nil, errors.New("empty spaces")
+		space, err := selectSpace(spaces, config.SpaceId)
+		if err != nil {
+			return nil, err
 		}
-		teambition.rootId = spaces[0].RootId
+		teambition.rootId = space.RootId
 		n := &Node{
 			NodeId: teambition.rootId,
 			Kind:   "folder",
@@ -152,20 +279,78 @@ func NewFs(ctx context.Context, config *Config) (Fs, error) {
 	}
 
 	// get driveId
-	{
-		var drive Drive
-		err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf("https://pan.teambition.com/pan/api/orgs/%s?orgId=%s", teambition.orgId, teambition.orgId), nil, &drive)
+	if config.DriveId != "" {
+		teambition.driveId = config.DriveId
+	} else {
+		drive, err := teambition.getDrive(ctx, teambition.orgId)
 		if err != nil {
 			return nil, errors.Wrap(err, "error getting driveId")
 		}
 		teambition.driveId = drive.Data.DriveId
 	}
 
+	if config.CachePath != "" {
+		scope := teambition.driveId + "/" + teambition.rootId
+		diskCache, err := OpenDiskCache(config.CachePath, scope, cacheSize, config.FolderCacheTTL, listTTL, config.RefreshCache)
+		if err != nil {
+			return nil, errors.Wrap(err, "error opening cache")
+		}
+		teambition.diskCache = diskCache
+		teambition.folderCache = diskCache
+		if !config.DisableListCache {
+			teambition.listingCache = diskCache.Listings()
+		}
+	}
+
 	return teambition, nil
 }
 
+func (teambition *Teambition) FolderCache() FolderCache {
+	return teambition.folderCache
+}
+
+// Close saves the caches to Config.CachePath, the Fs can still be used afterwards
+func (teambition *Teambition) Close() error {
+	if teambition.diskCache == nil {
+		return nil
+	}
+	return teambition.diskCache.Save()
+}
+
 // https://pan.teambition.com/pan/api/nodes?orgId=&driveId=&parentId=
 func (teambition *Teambition) listNodes(ctx context.Context, node *Node) (*Nodes, error) {
+	if teambition.listingCache != nil {
+		if data, ok := teambition.listingCache.Get(node.NodeId); ok {
+			return &Nodes{Data: data}, nil
+		}
+	}
+
+	value, err, shared := teambition.listFlight.Do(ctx, node.NodeId, func(ctx context.Context) (interface{}, error) {
+		generation := teambition.currentGeneration()
+		nodes, err := teambition.fetchNodes(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		if teambition.listingCache != nil {
+			teambition.putIfCurrent(generation, func() {
+				teambition.listingCache.Put(node.NodeId, nodes.Data)
+			})
+		}
+		return nodes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodes := value.(*Nodes)
+	if shared {
+		// every caller gets its own slice
+		return &Nodes{Data: append([]Node(nil), nodes.Data...)}, nil
+	}
+	return nodes, nil
+}
+
+// fetchNodes lists the children of node from the server, bypassing the listing cache
+func (teambition *Teambition) fetchNodes(ctx context.Context, node *Node) (*Nodes, error) {
 	format := "https://pan.teambition.com/pan/api/nodes?limit=10000&orderBy=name&orderDirection=asc&orgId=%s&driveId=%s&parentId=%s"
 	var nodes Nodes
 	err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf(format, teambition.orgId, teambition.driveId, node.NodeId), nil, &nodes)
@@ -175,25 +360,84 @@ func (teambition *Teambition) listNodes(ctx context.Context, node *Node) (*Nodes
 	return &nodes, nil
 }
 
+// currentGeneration returns the number of invalidations so far, to be passed to putIfCurrent
+func (teambition *Teambition) currentGeneration() uint64 {
+	teambition.listingMutex.Lock()
+	defer teambition.listingMutex.Unlock()
+	return teambition.listingGeneration
+}
+
+// putIfCurrent runs put unless the listings were invalidated since generation was taken
+func (teambition *Teambition) putIfCurrent(generation uint64, put func()) {
+	teambition.listingMutex.Lock()
+	defer teambition.listingMutex.Unlock()
+	if teambition.listingGeneration == generation {
+		put()
+	}
+}
+
+// invalidateListings drops the cached listings and missing names of the folders, or all of them when a NodeId is unknown
+func (teambition *Teambition) invalidateListings(nodeIds ...string) {
+	teambition.listingMutex.Lock()
+	defer teambition.listingMutex.Unlock()
+	teambition.listingGeneration++
+	if teambition.negativeCache != nil {
+		for _, nodeId := range nodeIds {
+			if nodeId == "" {
+				teambition.negativeCache.Clear()
+				break
+			}
+			teambition.negativeCache.Remove(nodeId)
+		}
+	}
+	if teambition.listingCache == nil {
+		return
+	}
+	for _, nodeId := range nodeIds {
+		if nodeId == "" {
+			teambition.listingCache.Clear()
+			return
+		}
+		teambition.listingCache.Remove(nodeId)
+	}
+}
+
 const FolderKind = "folder"
 const FileKind = "file"
 const AnyKind = "any"
 
 func (teambition *Teambition) findNameNode(ctx context.Context, node *Node, name string, kind string) (*Node, error) {
+	if teambition.negativeCache != nil && teambition.negativeCache.Missing(node.NodeId, name, kind) {
+		return nil, errors.Errorf(`can't find "%s", kind: "%s" under "%s"`, name, kind, node)
+	}
+
+	generation := teambition.currentGeneration()
 	nodes, err := teambition.listNodes(ctx, node)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	for _, d := range nodes.Data {
-		if d.Name == name && (kind == AnyKind || d.Kind == kind) {
-			return &d, nil
-		}
+	if d := findName(nodes.Data, name, kind); d != nil {
+		return d, nil
 	}
 
+	if teambition.negativeCache != nil {
+		teambition.putIfCurrent(generation, func() {
+			teambition.negativeCache.Put(node.NodeId, name, kind)
+		})
+	}
 	return nil, errors.Errorf(`can't find "%s", kind: "%s" under "%s"`, name, kind, node)
 }
 
+func findName(nodes []Node, name string, kind string) *Node {
+	for _, d := range nodes {
+		if d.Name == name && (kind == AnyKind || d.Kind == kind) {
+			return &d
+		}
+	}
+	return nil
+}
+
 // path must start with "/" and must not end with "/"
 func normalizePath(s string) string {
 	separator := "/"
@@ -221,19 +465,62 @@ func (teambition *Teambition) Get(ctx context.Context, path string, kind string)
 		return teambition.findNameNode(ctx, &teambition.rootNode, name, kind)
 	}
 
-	node, ok := teambition.folderCache.Get(parent)
-	if !ok {
-		_node, err := teambition.Get(ctx, parent, FolderKind)
+	node, stale, ok := teambition.folderCache.Lookup(parent)
+	if ok && stale {
+		if teambition.config.StaleWhileRevalidate {
+			teambition.revalidateInBackground(parent, node)
+		} else {
+			_node, err := teambition.resolveFolder(ctx, parent, node)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			node = _node
+		}
+	} else if !ok {
+		_node, err := teambition.resolveFolder(ctx, parent, nil)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 		node = _node
-		teambition.folderCache.Put(parent, node)
 	}
 
 	return teambition.findNameNode(ctx, node, name, kind)
 }
 
+// resolveFolder resolves path and caches it, when the folder cached as old changed its subtree is invalidated.
+// Concurrent resolutions of the same path share one lookup.
+func (teambition *Teambition) resolveFolder(ctx context.Context, path string, old *Node) (*Node, error) {
+	value, err, _ := teambition.resolveFlight.Do(ctx, path, func(ctx context.Context) (interface{}, error) {
+		node, err := teambition.Get(ctx, path, FolderKind)
+		if old != nil && (err != nil || node.NodeId != old.NodeId) {
+			teambition.folderCache.InvalidatePrefix(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		teambition.folderCache.Put(path, node)
+		return node, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	node := *value.(*Node)
+	return &node, nil
+}
+
+// revalidateInBackground resolves a stale path while the stale entry keeps being served
+func (teambition *Teambition) revalidateInBackground(path string, old *Node) {
+	if _, loaded := teambition.revalidating.LoadOrStore(path, true); loaded {
+		return
+	}
+	go func() {
+		defer teambition.revalidating.Delete(path)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, _ = teambition.resolveFolder(ctx, path, old)
+	}()
+}
+
 func findNodeError(err error, path string) error {
 	return errors.Wrapf(err, `error finding node of "%s"`, path)
 }
@@ -254,18 +541,28 @@ func (teambition *Teambition) List(ctx context.Context, path string) ([]Node, er
 }
 
 func (teambition *Teambition) createFolderInternal(ctx context.Context, parent string, name string) (*Node, error) {
-	teambition.mutex.Lock()
-	defer teambition.mutex.Unlock()
-
-	node, err := teambition.Get(ctx, parent+"/"+name, FolderKind)
-	if err == nil {
-		return node, nil
-	}
+	unlock := teambition.folderLocks.Lock(parent + "/" + name)
+	defer unlock()
 
-	node, err = teambition.Get(ctx, parent, FolderKind)
+	node, err := teambition.Get(ctx, parent, FolderKind)
 	if err != nil {
 		return nil, findNodeError(err, parent)
 	}
+	nodes, err := teambition.listNodes(ctx, node)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing nodes of "%s"`, node)
+	}
+	if folder := findName(nodes.Data, name, FolderKind); folder != nil {
+		return folder, nil
+	}
+	// the cached listing may predate the folder created by the previous holder of the lock
+	nodes, err = teambition.fetchNodes(ctx, node)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing nodes of "%s"`, node)
+	}
+	if folder := findName(nodes.Data, name, FolderKind); folder != nil {
+		return folder, nil
+	}
 	body := map[string]string{
 		"ccpParentId":   node.NodeId,
 		"checkNameMode": "refuse",
@@ -278,7 +575,14 @@ func (teambition *Teambition) createFolderInternal(ctx context.Context, parent s
 	}
 	var createdNode [1]Node
 	err = teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/folder", &body, &createdNode)
+	teambition.invalidateListings(node.NodeId)
 	if err != nil {
+		// the folder may have been created by another client in the meantime
+		if nodes, lerr := teambition.fetchNodes(ctx, node); lerr == nil {
+			if folder := findName(nodes.Data, name, FolderKind); folder != nil {
+				return folder, nil
+			}
+		}
 		return nil, errors.Wrap(err, "error posting create folder request")
 	}
 	return &createdNode[0], nil
@@ -330,63 +634,173 @@ func (teambition *Teambition) Rename(ctx context.Context, node *Node, newName st
 		"ccpFileId": node.NodeId,
 		"name":      newName,
 	}
+	paths := teambition.folderPaths([]*Node{node})
 	err := teambition.jsonRequest(ctx, "PUT", fmt.Sprintf("https://pan.teambition.com/pan/api/nodes/%s", node.NodeId), &body, nil)
-	if node.Kind == FolderKind {
-		teambition.folderCache.Clear()
-	}
+	teambition.invalidateListings(node.ParentId)
 	if err != nil {
+		teambition.invalidateFolders(paths)
 		return errors.Wrap(err, `error posting rename request`)
 	}
+	if path, ok := paths[0]; ok && path == "" {
+		teambition.folderCache.Clear()
+	} else if ok {
+		renamed := *node
+		renamed.Name = newName
+		newPath := path[:strings.LastIndex(path, "/")] + "/" + newName
+		teambition.folderCache.MovePrefix(path, newPath)
+		teambition.folderCache.Put(newPath, &renamed)
+	}
 	return nil
 }
 
-func (teambition *Teambition) Move(ctx context.Context, node *Node, parent *Node) error {
-	if err := teambition.checkRoot(node); err != nil {
-		return err
+func nodeIds(nodes []*Node) []map[string]string {
+	ids := make([]map[string]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = map[string]string{
+			"id":        node.NodeId,
+			"ccpFileId": node.NodeId,
+		}
+	}
+	return ids
+}
+
+// cachedPath returns the path of a folder node known to the folder cache, "" for the root
+func (teambition *Teambition) cachedPath(node *Node) (string, bool) {
+	if node.NodeId == teambition.rootId {
+		return "", true
+	}
+	// Entries doesn't count as lookups nor refresh the recency of the entries
+	for _, entry := range teambition.folderCache.Entries() {
+		if entry.Node.NodeId == node.NodeId {
+			return entry.Path, true
+		}
+	}
+	return "", false
+}
+
+// folderPaths returns the paths of the folder nodes, by index, as far as the folder cache knows them.
+// A folder missing from the cache is located through its cached parent, a folder which can't be located maps to ""
+func (teambition *Teambition) folderPaths(nodes []*Node) map[int]string {
+	paths := map[int]string{}
+	for i, node := range nodes {
+		if node.Kind != FolderKind {
+			continue
+		}
+		if path, ok := teambition.cachedPath(node); ok {
+			paths[i] = path
+		} else if path, ok := teambition.cachedPath(&Node{NodeId: node.ParentId}); ok && node.ParentId != "" {
+			paths[i] = path + "/" + node.Name
+		} else {
+			paths[i] = ""
+		}
+	}
+	return paths
+}
+
+// invalidateFolders drops the cached subtrees of folderPaths, or the whole cache when a path is unknown
+func (teambition *Teambition) invalidateFolders(paths map[int]string) {
+	for _, path := range paths {
+		if path == "" {
+			teambition.folderCache.Clear()
+			return
+		}
+		teambition.folderCache.InvalidatePrefix(path)
 	}
+}
 
-	if parent == nil {
-		return errors.New("parent node is empty")
+// updateMovedFolder re-keys the cached subtree of a folder moved from oldPath under parent
+func (teambition *Teambition) updateMovedFolder(oldPath string, moved *Node, parent *Node) {
+	if oldPath == "" {
+		teambition.folderCache.Clear()
+		return
+	}
+	parentPath, ok := teambition.cachedPath(parent)
+	if !ok {
+		teambition.folderCache.InvalidatePrefix(oldPath)
+		return
 	}
+	newPath := parentPath + "/" + moved.Name
+	teambition.folderCache.MovePrefix(oldPath, newPath)
+	teambition.folderCache.Put(newPath, moved)
+}
+
+func (teambition *Teambition) moveNodes(ctx context.Context, nodes []*Node, parent *Node) ([]Node, string, error) {
 	body := map[string]interface{}{
 		"orgId":     teambition.orgId,
 		"driveId":   teambition.driveId,
 		"sameLevel": false,
-		"ids": []map[string]string{
-			{
-				"id":        node.NodeId,
-				"ccpFileId": node.NodeId,
-			},
-		},
-		"parentId": parent.NodeId,
+		"ids":       nodeIds(nodes),
+		"parentId":  parent.NodeId,
 	}
-	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/move", &body, nil)
-	if node.Kind == FolderKind {
-		teambition.folderCache.Clear()
+	paths := teambition.folderPaths(nodes)
+	var raw json.RawMessage
+	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/move", &body, &raw)
+	teambition.invalidateListings(parent.NodeId)
+	for _, node := range nodes {
+		teambition.invalidateListings(node.ParentId)
 	}
 	if err != nil {
-		return errors.Wrap(err, `error posting move request`)
+		teambition.invalidateFolders(paths)
+		return nil, "", errors.Wrap(err, `error posting move request`)
 	}
-	return nil
+	results, taskId := decodeNodeResults(raw)
+
+	moved := make([]Node, len(nodes))
+	for i, node := range nodes {
+		if i < len(results) && results[i].NodeId == node.NodeId {
+			moved[i] = results[i]
+		} else {
+			moved[i] = *node
+			moved[i].ParentId = parent.NodeId
+		}
+		if path, ok := paths[i]; ok {
+			teambition.updateMovedFolder(path, &moved[i], parent)
+		}
+	}
+	return moved, taskId, nil
 }
 
-func (teambition *Teambition) Remove(ctx context.Context, node *Node) error {
-	if err := teambition.checkRoot(node); err != nil {
-		return err
+// Move waits until the server moved node, MoveAsync returns as soon as the move started
+func (teambition *Teambition) Move(ctx context.Context, node *Node, parent *Node) (*Node, error) {
+	task, err := teambition.MoveAsync(ctx, node, parent)
+	if err != nil {
+		return nil, err
 	}
+	if err := task.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return task.Node, nil
+}
 
+func (teambition *Teambition) removeNodes(ctx context.Context, nodes []*Node) (string, error) {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.NodeId
+	}
 	body := map[string]interface{}{
-		"nodeIds": []string{node.NodeId},
+		"nodeIds": ids,
 		"orgId":   teambition.orgId,
 	}
-	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/archive", &body, nil)
-	if node.Kind == FolderKind {
-		teambition.folderCache.Clear()
+	paths := teambition.folderPaths(nodes)
+	var raw json.RawMessage
+	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/archive", &body, &raw)
+	teambition.invalidateFolders(paths)
+	for _, node := range nodes {
+		teambition.invalidateListings(node.ParentId, node.NodeId)
 	}
 	if err != nil {
-		return errors.Wrap(err, `error posting remove request`)
+		return "", errors.Wrap(err, `error posting remove request`)
 	}
-	return nil
+	_, taskId := decodeNodeResults(raw)
+	return taskId, nil
+}
+
+func (teambition *Teambition) Remove(ctx context.Context, node *Node) error {
+	task, err := teambition.RemoveAsync(ctx, node)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
 }
 
 func (teambition *Teambition) getByNode(ctx context.Context, node *Node) (*Node, error) {
@@ -415,13 +829,23 @@ func (teambition *Teambition) Open(ctx context.Context, node *Node, headers map[
 
 	res, err := teambition.request(ctx, "GET", downloadUrl, headers, nil)
 	if err != nil {
-		return nil, errors.Wrapf(err, `error downloading "%s"`, downloadUrl)
+		return nil, errors.Wrapf(err, `error downloading "%s"`, redactUrl(downloadUrl))
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, errors.Errorf(`error downloading "%s": %s`, redactUrl(downloadUrl), res.Status)
 	}
 
 	return res.Body, nil
 }
 
 func (teambition *Teambition) CreateFile(ctx context.Context, path string, size int64, in io.Reader, overwrite bool) (*Node, error) {
+	if teambition.config.CheckQuota {
+		if err := teambition.checkQuota(ctx, size); err != nil {
+			return nil, err
+		}
+	}
+
 	path = normalizePath(path)
 	i := strings.LastIndex(path, "/")
 	parent := path[:i]
@@ -457,6 +881,7 @@ func (teambition *Teambition) CreateFile(ctx context.Context, path string, size
 			},
 		}
 		err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/file", &body, &uploadResults)
+		teambition.invalidateListings(node.NodeId)
 		if err != nil {
 			return errors.Wrap(err, `error posting create file request`)
 		}
@@ -497,7 +922,7 @@ func (teambition *Teambition) CreateFile(ctx context.Context, path string, size
 		req.Header.Set("Content-Type", "")
 		ursp, err := teambition.httpClient.Do(req)
 		if err != nil {
-			return nil, errors.Wrap(err, "error uploading file")
+			return nil, errors.Wrap(redactError(err), "error uploading file")
 		}
 		defer ursp.Body.Close()
 	}
@@ -513,6 +938,7 @@ func (teambition *Teambition) CreateFile(ctx context.Context, path string, size
 		}
 
 		err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/complete", &body, &createdNode)
+		teambition.invalidateListings(node.NodeId)
 		if err != nil {
 			return nil, errors.Wrap(err, `error posting upload complete request`)
 		}
@@ -520,28 +946,136 @@ func (teambition *Teambition) CreateFile(ctx context.Context, path string, size
 	return &createdNode, nil
 }
 
-func (teambition *Teambition) Copy(ctx context.Context, node *Node, parent *Node) error {
-	if err := teambition.checkRoot(node); err != nil {
-		return err
+// childIds returns the NodeIds of the children of parent, to tell the copies created afterwards by findCopies
+func (teambition *Teambition) childIds(ctx context.Context, parent *Node) (map[string]bool, error) {
+	nodes, err := teambition.fetchNodes(ctx, parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing "%s"`, parent)
+	}
+	ids := make(map[string]bool, len(nodes.Data))
+	for _, node := range nodes.Data {
+		ids[node.NodeId] = true
 	}
+	return ids, nil
+}
 
-	if parent == nil {
-		return errors.New("parent node is empty")
+// isCopyName reports whether name is the name of a copy of a node named original,
+// which may have been renamed like "2(1).jpg" or "2 (1).jpg"
+func isCopyName(name string, original string) bool {
+	if name == original {
+		return true
 	}
+	ext := path.Ext(original)
+	if ext == original {
+		ext = ""
+	}
+	base := strings.TrimSuffix(original, ext)
+	if len(name) < len(base)+len(ext) || !strings.HasPrefix(name, base) || !strings.HasSuffix(name, ext) {
+		return false
+	}
+	suffix := strings.TrimPrefix(name[len(base):len(name)-len(ext)], " ")
+	if len(suffix) < 3 || suffix[0] != '(' || suffix[len(suffix)-1] != ')' {
+		return false
+	}
+	for _, c := range suffix[1 : len(suffix)-1] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// findCopies matches the nodes and the children of parent which are not in existing, in the order of nodes
+func (teambition *Teambition) findCopies(ctx context.Context, nodes []*Node, parent *Node, existing map[string]bool) ([]Node, error) {
+	listing, err := teambition.fetchNodes(ctx, parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing "%s"`, parent)
+	}
+	var added []Node
+	for _, node := range listing.Data {
+		if !existing[node.NodeId] {
+			added = append(added, node)
+		}
+	}
+
+	copies := make([]Node, len(nodes))
+	for i, node := range nodes {
+		match := -1
+		for j, candidate := range added {
+			if candidate.Kind != node.Kind || !isCopyName(candidate.Name, node.Name) {
+				continue
+			}
+			if match < 0 || candidate.Name == node.Name {
+				match = j
+			}
+		}
+		if match < 0 {
+			return nil, errors.Errorf(`can't find the copy of "%s" under "%s"`, node, parent)
+		}
+		copies[i] = added[match]
+		added = append(added[:match], added[match+1:]...)
+	}
+	return copies, nil
+}
+
+// copyNodes copies nodes under parent, existing are the NodeIds of the children of parent before the copy
+func (teambition *Teambition) copyNodes(ctx context.Context, nodes []*Node, parent *Node, existing map[string]bool) ([]Node, string, error) {
 	body := map[string]interface{}{
-		"orgId":   teambition.orgId,
-		"driveId": teambition.driveId,
-		"ids": []map[string]string{
-			{
-				"id":        node.NodeId,
-				"ccpFileId": node.NodeId,
-			},
-		},
+		"orgId":    teambition.orgId,
+		"driveId":  teambition.driveId,
+		"ids":      nodeIds(nodes),
 		"parentId": parent.NodeId,
 	}
-	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/copy", &body, nil)
+	var raw json.RawMessage
+	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/copy", &body, &raw)
+	teambition.invalidateListings(parent.NodeId)
 	if err != nil {
-		return errors.Wrap(err, `error posting copy request`)
+		return nil, "", errors.Wrap(err, `error posting copy request`)
 	}
-	return nil
+	results, taskId := decodeNodeResults(raw)
+
+	var found []Node
+	copied := make([]Node, len(nodes))
+	for i, node := range nodes {
+		if i < len(results) && results[i].NodeId != "" {
+			copied[i] = results[i]
+		} else if taskId != "" {
+			// the copy is created in the background, its NodeId is unknown yet
+			copied[i] = Node{Kind: node.Kind, Name: node.Name, ParentId: parent.NodeId, Size: node.Size}
+			continue
+		} else {
+			// the response lacks the copies, they are the new children of parent
+			if found == nil {
+				found, err = teambition.findCopies(ctx, nodes, parent, existing)
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			copied[i] = found[i]
+		}
+		teambition.cacheCopiedFolder(&copied[i], parent)
+	}
+	return copied, taskId, nil
+}
+
+// cacheCopiedFolder caches the path of a copied folder when the path of its parent is known
+func (teambition *Teambition) cacheCopiedFolder(copied *Node, parent *Node) {
+	if copied.Kind != FolderKind {
+		return
+	}
+	if parentPath, ok := teambition.cachedPath(parent); ok {
+		teambition.folderCache.Put(parentPath+"/"+copied.Name, copied)
+	}
+}
+
+// Copy waits until the copy exists and returns it with its NodeId, CopyAsync returns as soon as the copy started
+func (teambition *Teambition) Copy(ctx context.Context, node *Node, parent *Node) (*Node, error) {
+	task, err := teambition.CopyAsync(ctx, node, parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := task.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return task.Node, nil
 }
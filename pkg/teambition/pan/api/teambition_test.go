@@ -7,22 +7,20 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
 var fs Fs
 
 func setup(t *testing.T) context.Context {
-	cookie := ""
-	cb, err := ioutil.ReadFile("../../../../.cookie")
-	if err == nil {
-		cookie = string(cb)
-	}
 	config := &Config{
-		Cookie: cookie,
+		Credentials: FileCredentials{Path: "../../../../.cookie"},
+		CookieStore: FileCookieStore{Path: "../../../../.cookie"},
 	}
 
 	ctx := context.Background()
+	var err error
 	fs, err = NewFs(ctx, config)
 	require.NoError(t, err)
 	return ctx
@@ -57,8 +55,9 @@ func TestMove(t *testing.T) {
 	require.NoError(t, err)
 	newNode, err := fs.Get(ctx, "/", FolderKind)
 	require.NoError(t, err)
-	err = fs.Move(ctx, node, newNode)
+	moved, err := fs.Move(ctx, node, newNode)
 	require.NoError(t, err)
+	require.Equal(t, newNode.NodeId, moved.ParentId)
 }
 
 func TestRemove(t *testing.T) {
@@ -113,8 +112,9 @@ func TestCopy(t *testing.T) {
 	require.NoError(t, err)
 	parent, err := fs.Get(ctx, "/", FolderKind)
 	require.NoError(t, err)
-	err = fs.Copy(ctx, node, parent)
+	copied, err := fs.Copy(ctx, node, parent)
 	require.NoError(t, err)
+	require.NotEqual(t, node.NodeId, copied.NodeId)
 }
 
 func TestGet(t *testing.T) {
@@ -125,3 +125,153 @@ func TestGet(t *testing.T) {
 	node, err = fs.Get(ctx, "/media/not-exist.jpg", FileKind)
 	require.NoError(t, err)
 }
+
+func TestSyncDown(t *testing.T) {
+	ctx := setup(t)
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	err = SyncDown(ctx, fs, "/media", dir, &SyncDownOptions{Prune: true})
+	require.NoError(t, err)
+}
+
+func TestSync(t *testing.T) {
+	ctx := setup(t)
+	dir, err := ioutil.TempDir("", "teambition")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	err = Sync(ctx, fs, "/media", dir, nil)
+	require.NoError(t, err)
+	err = Sync(ctx, fs, "/media", dir, nil)
+	require.NoError(t, err)
+}
+
+func TestTrash(t *testing.T) {
+	ctx := setup(t)
+	trash := fs.(TrashFs)
+	node, err := fs.CreateFolder(ctx, "/test6")
+	require.NoError(t, err)
+	err = fs.Remove(ctx, node)
+	require.NoError(t, err)
+	nodes, err := trash.ListTrash(ctx)
+	require.NoError(t, err)
+	println(fmt.Sprintf("size: %v, %v", len(nodes), nodes))
+	err = trash.Restore(ctx, node)
+	require.NoError(t, err)
+	_, err = fs.Get(ctx, "/test6", FolderKind)
+	require.NoError(t, err)
+	err = fs.Remove(ctx, node)
+	require.NoError(t, err)
+	err = trash.DeletePermanently(ctx, node)
+	require.NoError(t, err)
+}
+
+func TestRemoveMany(t *testing.T) {
+	ctx := setup(t)
+	var nodes []*Node
+	for i := 0; i < 3; i++ {
+		node, err := fs.CreateFolder(ctx, fmt.Sprintf("/test7/%d", i))
+		require.NoError(t, err)
+		nodes = append(nodes, node)
+	}
+	for _, result := range fs.(BatchFs).RemoveMany(ctx, nodes) {
+		require.NoError(t, result.Err)
+	}
+}
+
+func TestCopyAsync(t *testing.T) {
+	ctx := setup(t)
+	node, err := fs.Get(ctx, "/media", FolderKind)
+	require.NoError(t, err)
+	parent, err := fs.CreateFolder(ctx, "/test8")
+	require.NoError(t, err)
+	task, err := fs.(AsyncFs).CopyAsync(ctx, node, parent)
+	require.NoError(t, err)
+	err = task.Poll(ctx, func(status *TaskStatus) {
+		fmt.Println(status)
+	})
+	require.NoError(t, err)
+	task, err = fs.(AsyncFs).RemoveAsync(ctx, parent)
+	require.NoError(t, err)
+	require.NoError(t, task.Wait(ctx))
+}
+
+func TestMovePath(t *testing.T) {
+	ctx := setup(t)
+	_, err := fs.CreateFolder(ctx, "/test9/a")
+	require.NoError(t, err)
+	pathFs := fs.(PathFs)
+	node, err := pathFs.CopyPath(ctx, "/test9/a", "/test10/b", ConflictError)
+	require.NoError(t, err)
+	require.Equal(t, "b", node.Name)
+	_, err = pathFs.MovePath(ctx, "/test9/a", "/test10/b", ConflictError)
+	require.True(t, errors.Is(err, ErrExists))
+	node, err = pathFs.MovePath(ctx, "/test9/a", "/test10/b", ConflictRename)
+	require.NoError(t, err)
+	require.Equal(t, "b (1)", node.Name)
+	for _, p := range []string{"/test9", "/test10"} {
+		node, err = fs.Get(ctx, p, FolderKind)
+		require.NoError(t, err)
+		require.NoError(t, fs.Remove(ctx, node))
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	ctx := setup(t)
+	account, err := fs.(AccountFs).Whoami(ctx)
+	require.NoError(t, err)
+	fmt.Println(account)
+	require.NoError(t, fs.(AccountFs).Ping(ctx))
+}
+
+func TestAbout(t *testing.T) {
+	ctx := setup(t)
+	usage, err := fs.(AboutFs).About(ctx)
+	require.NoError(t, err)
+	fmt.Println(usage)
+}
+
+func TestListSpaces(t *testing.T) {
+	ctx := setup(t)
+	orgFs := fs.(OrgFs)
+	orgs, err := orgFs.ListOrganizations(ctx)
+	require.NoError(t, err)
+	for _, org := range orgs {
+		spaces, err := orgFs.ListSpaces(ctx, org.Id)
+		require.NoError(t, err)
+		drives, err := orgFs.ListDrives(ctx, org.Id)
+		require.NoError(t, err)
+		fmt.Println(org, spaces, len(drives))
+	}
+}
+
+func TestMultiFs(t *testing.T) {
+	ctx := setup(t)
+	m := NewMultiFs(map[string]Fs{"work": fs})
+	nodes, err := m.List(ctx, "/")
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	node, err := m.Get(ctx, "/work/media/2.jpg", FileKind)
+	require.NoError(t, err)
+	fd, err := m.Open(ctx, node, map[string]string{})
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+}
+
+func TestPathOf(t *testing.T) {
+	ctx := setup(t)
+	_, err := fs.CreateFolder(ctx, "/test/path")
+	require.NoError(t, err)
+	node, err := fs.Get(ctx, "/test/path", FolderKind)
+	require.NoError(t, err)
+
+	byId, err := fs.(NodeIdFs).GetById(ctx, node.NodeId)
+	require.NoError(t, err)
+	require.Equal(t, "path", byId.Name)
+
+	fs, err = NewFs(ctx, &Config{Credentials: FileCredentials{Path: "../../../../.cookie"}})
+	require.NoError(t, err)
+	path, err := fs.(NodeIdFs).PathOf(ctx, &Node{NodeId: node.NodeId})
+	require.NoError(t, err)
+	require.Equal(t, "/test/path", path)
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrExists = errors.New("node already exists")
+
+// ConflictMode decides what MovePath and CopyPath do when the destination path already exists
+type ConflictMode int
+
+const (
+	// ConflictError fails with ErrExists
+	ConflictError ConflictMode = iota
+	// ConflictOverwrite removes the existing destination once the transfer succeeded
+	ConflictOverwrite
+	// ConflictRename picks a free name like "name (1).ext"
+	ConflictRename
+)
+
+// PathFs moves and copies by path, creating missing destination folders and renaming in the same call
+type PathFs interface {
+	MovePath(ctx context.Context, src string, dst string, mode ConflictMode) (*Node, error)
+	CopyPath(ctx context.Context, src string, dst string, mode ConflictMode) (*Node, error)
+}
+
+type transfer struct {
+	node     *Node
+	parent   *Node
+	name     string
+	siblings map[string]bool
+	// replace is the existing destination removed by ConflictOverwrite
+	replace *Node
+}
+
+func freeName(name string, siblings map[string]bool) string {
+	ext := path.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !siblings[candidate] {
+			return candidate
+		}
+	}
+}
+
+// prepareTransfer resolves src, creates the parent of dst and applies mode when dst exists
+func (teambition *Teambition) prepareTransfer(ctx context.Context, src string, dst string, mode ConflictMode) (*transfer, error) {
+	src = normalizePath(src)
+	dst = normalizePath(dst)
+	if dst == "/" {
+		return nil, errors.New("can't operate on root")
+	}
+
+	node, err := teambition.Get(ctx, src, AnyKind)
+	if err != nil {
+		return nil, findNodeError(err, src)
+	}
+	if node.Kind == FolderKind && strings.HasPrefix(dst, src+"/") {
+		return nil, errors.Errorf(`can't transfer "%s" into itself`, src)
+	}
+
+	i := strings.LastIndex(dst, "/")
+	parentPath := dst[:i]
+	name := dst[i+1:]
+	parent := &teambition.rootNode
+	if parentPath != "" {
+		parent, err = teambition.CreateFolder(ctx, parentPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error creating folder "%s"`, parentPath)
+		}
+	}
+
+	nodes, err := teambition.listNodes(ctx, parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error listing nodes of "%s"`, parent)
+	}
+	siblings := make(map[string]bool, len(nodes.Data))
+	var existing *Node
+	for i := range nodes.Data {
+		siblings[nodes.Data[i].Name] = true
+		if nodes.Data[i].Name == name {
+			existing = &nodes.Data[i]
+		}
+	}
+
+	if existing != nil && existing.NodeId != node.NodeId {
+		switch mode {
+		case ConflictOverwrite:
+		case ConflictRename:
+			name = freeName(name, siblings)
+			existing = nil
+		default:
+			return nil, errors.Wrapf(ErrExists, `"%s"`, dst)
+		}
+	} else {
+		existing = nil
+	}
+
+	return &transfer{node: node, parent: parent, name: name, siblings: siblings, replace: existing}, nil
+}
+
+// finish removes the destination replaced by node, then gives node its final name
+func (teambition *Teambition) finish(ctx context.Context, t *transfer, node *Node) (*Node, error) {
+	if t.replace != nil {
+		if err := teambition.Remove(ctx, t.replace); err != nil {
+			return nil, errors.Wrapf(err, `error removing existing "%s"`, t.replace)
+		}
+	}
+	return teambition.renameTo(ctx, node, t.name)
+}
+
+func (teambition *Teambition) renameTo(ctx context.Context, node *Node, name string) (*Node, error) {
+	if node.Name == name {
+		return node, nil
+	}
+	if err := teambition.Rename(ctx, node, name); err != nil {
+		return nil, err
+	}
+	renamed := *node
+	renamed.Name = name
+	return &renamed, nil
+}
+
+// MovePath moves src to dst, renaming it when the last element of dst differs.
+// When the move fails, the node gets its original name back.
+func (teambition *Teambition) MovePath(ctx context.Context, src string, dst string, mode ConflictMode) (*Node, error) {
+	t, err := teambition.prepareTransfer(ctx, src, dst, mode)
+	if err != nil {
+		return nil, err
+	}
+	node := t.node
+	if node.ParentId == t.parent.NodeId {
+		return teambition.finish(ctx, t, node)
+	}
+
+	// rename before moving when the current name is taken in the destination folder,
+	// to a name free in both the source and the destination folders
+	moving := node
+	if t.siblings[node.Name] {
+		nodes, err := teambition.listNodes(ctx, &Node{NodeId: node.ParentId})
+		if err != nil {
+			return nil, errors.Wrapf(err, `error listing the folder of "%s"`, node)
+		}
+		taken := make(map[string]bool, len(t.siblings)+len(nodes.Data))
+		for name := range t.siblings {
+			taken[name] = true
+		}
+		for _, sibling := range nodes.Data {
+			taken[sibling.Name] = true
+		}
+		name := t.name
+		if taken[name] {
+			name = freeName(name, taken)
+		}
+		if moving, err = teambition.renameTo(ctx, node, name); err != nil {
+			return nil, err
+		}
+	}
+	moved, err := teambition.Move(ctx, moving, t.parent)
+	if err != nil {
+		if moving != node {
+			if rerr := teambition.Rename(ctx, moving, node.Name); rerr != nil {
+				return nil, errors.Wrapf(err, `error renaming "%s" back to "%s" (%v) after the move failed`, moving, node.Name, rerr)
+			}
+		}
+		return nil, err
+	}
+	return teambition.finish(ctx, t, moved)
+}
+
+// CopyPath copies src to dst, renaming the copy when the last element of dst differs.
+// Copying a node onto itself only succeeds with ConflictRename.
+func (teambition *Teambition) CopyPath(ctx context.Context, src string, dst string, mode ConflictMode) (*Node, error) {
+	onItself := normalizePath(src) == normalizePath(dst)
+	if onItself && mode != ConflictRename {
+		return nil, errors.Wrapf(ErrExists, `"%s"`, normalizePath(dst))
+	}
+	t, err := teambition.prepareTransfer(ctx, src, dst, mode)
+	if err != nil {
+		return nil, err
+	}
+	if onItself {
+		t.name = freeName(t.name, t.siblings)
+	}
+
+	copied, err := teambition.Copy(ctx, t.node, t.parent)
+	if err != nil {
+		return nil, err
+	}
+	return teambition.finish(ctx, t, copied)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMovePathRollback(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	nodes := []Node{
+		{NodeId: "src", ParentId: "root", Name: "a.txt", Kind: FileKind},
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "old", ParentId: "dst", Name: "a.txt", Kind: FileKind},
+	}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nodes }))
+	server.handle("POST /pan/api/nodes/folder", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []Node{nodes[1]}
+	})
+	var names []string
+	server.handle("PUT /pan/api/nodes/src", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		names = append(names, body["name"].(string))
+		return http.StatusOK, map[string]string{}
+	})
+	server.handle("POST /pan/api/nodes/move", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusInternalServerError, []byte("<html>")
+	})
+
+	_, err := teambition.MovePath(context.Background(), "/a.txt", "/dst/a.txt", ConflictOverwrite)
+	require.Error(t, err)
+	require.Equal(t, []string{"a (1).txt", "a.txt"}, names)
+	require.Equal(t, 0, server.count("POST /pan/api/nodes/archive"))
+}
+
+func TestCopyPathOntoItself(t *testing.T) {
+	teambition, _ := newFakeTeambition(t)
+	_, err := teambition.CopyPath(context.Background(), "/a.txt", "/a.txt/", ConflictOverwrite)
+	require.True(t, errors.Is(err, ErrExists))
+}
+
+func TestMovePathOverwrite(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	nodes := []Node{
+		{NodeId: "src", ParentId: "root", Name: "a.txt", Kind: FileKind},
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "old", ParentId: "dst", Name: "a.txt", Kind: FileKind},
+	}
+	var calls []string
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nodes }))
+	server.handle("PUT /pan/api/nodes/src", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		calls = append(calls, "rename "+body["name"].(string))
+		return http.StatusOK, map[string]string{}
+	})
+	server.handle("POST /pan/api/nodes/move", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		calls = append(calls, "move")
+		return http.StatusOK, []interface{}{}
+	})
+	server.handle("POST /pan/api/nodes/archive", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		calls = append(calls, "remove")
+		return http.StatusOK, []interface{}{}
+	})
+
+	moved, err := teambition.MovePath(context.Background(), "/a.txt", "/dst/a.txt", ConflictOverwrite)
+	require.NoError(t, err)
+	require.Equal(t, "a.txt", moved.Name)
+	require.Equal(t, []string{"rename a (1).txt", "move", "remove", "rename a.txt"}, calls)
+}
+
+func TestFreeName(t *testing.T) {
+	siblings := map[string]bool{"a.txt": true, "a (1).txt": true, "b": true}
+	if name := freeName("a.txt", siblings); name != "a (2).txt" {
+		t.Errorf(`expected "%s", but get "%s"`, "a (2).txt", name)
+	}
+	if name := freeName("b", siblings); name != "b (1)" {
+		t.Errorf(`expected "%s", but get "%s"`, "b (1)", name)
+	}
+}
+
+func TestMovePathTemporaryNameFreeInSource(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	nodes := []Node{
+		{NodeId: "src", ParentId: "root", Name: "a.txt", Kind: FileKind},
+		{NodeId: "other", ParentId: "root", Name: "a (1).txt", Kind: FileKind},
+		{NodeId: "dst", ParentId: "root", Name: "dst", Kind: FolderKind},
+		{NodeId: "old", ParentId: "dst", Name: "a.txt", Kind: FileKind},
+	}
+	var names []string
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node { return nodes }))
+	server.handle("PUT /pan/api/nodes/src", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		names = append(names, body["name"].(string))
+		return http.StatusOK, map[string]string{}
+	})
+	server.handle("POST /pan/api/nodes/move", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []interface{}{}
+	})
+	server.handle("POST /pan/api/nodes/archive", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []interface{}{}
+	})
+
+	_, err := teambition.MovePath(context.Background(), "/a.txt", "/dst/a.txt", ConflictOverwrite)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a (2).txt", "a.txt"}, names)
+}
+
+func TestCopyPathOntoItselfRenamed(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	var mutex sync.Mutex
+	nodes := []Node{{NodeId: "src", ParentId: "root", Name: "a.txt", Kind: FileKind}}
+	server.handle("GET /pan/api/nodes", listRoute(func() []Node {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Node(nil), nodes...)
+	}))
+	server.handle("POST /pan/api/nodes/copy", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		nodes = append(nodes, Node{NodeId: "new", ParentId: "root", Name: "a(1).txt", Kind: FileKind})
+		return http.StatusOK, []interface{}{}
+	})
+	var names []string
+	server.handle("PUT /pan/api/nodes/new", func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		names = append(names, body["name"].(string))
+		return http.StatusOK, map[string]string{}
+	})
+
+	copied, err := teambition.CopyPath(context.Background(), "/a.txt", "/a.txt", ConflictRename)
+	require.NoError(t, err)
+	require.Equal(t, "new", copied.NodeId)
+	require.Equal(t, "a (1).txt", copied.Name)
+	require.Equal(t, []string{"a (1).txt"}, names)
+}
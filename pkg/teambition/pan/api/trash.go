@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// TrashFs manages nodes moved to the recycle bin by Remove
+type TrashFs interface {
+	ListTrash(ctx context.Context) ([]Node, error)
+	Restore(ctx context.Context, node *Node) error
+	DeletePermanently(ctx context.Context, node *Node) error
+	PurgeTrash(ctx context.Context) error
+}
+
+// https://pan.teambition.com/pan/api/nodes/archived?orgId=&driveId=
+func (teambition *Teambition) ListTrash(ctx context.Context) ([]Node, error) {
+	format := "https://pan.teambition.com/pan/api/nodes/archived?limit=10000&orderBy=updated&orderDirection=desc&orgId=%s&driveId=%s"
+	var nodes Nodes
+	err := teambition.jsonRequest(ctx, "GET", fmt.Sprintf(format, teambition.orgId, teambition.driveId), nil, &nodes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing trash")
+	}
+	return nodes.Data, nil
+}
+
+func (teambition *Teambition) Restore(ctx context.Context, node *Node) error {
+	if err := teambition.checkRoot(node); err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"nodeIds": []string{node.NodeId},
+		"orgId":   teambition.orgId,
+		"driveId": teambition.driveId,
+	}
+	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/restore", &body, nil)
+	teambition.invalidateListings(node.ParentId)
+	if err != nil {
+		return errors.Wrap(err, `error posting restore request`)
+	}
+	return nil
+}
+
+func (teambition *Teambition) DeletePermanently(ctx context.Context, node *Node) error {
+	if err := teambition.checkRoot(node); err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"nodeIds": []string{node.NodeId},
+		"orgId":   teambition.orgId,
+		"driveId": teambition.driveId,
+	}
+	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/delete", &body, nil)
+	if err != nil {
+		return errors.Wrap(err, `error posting delete request`)
+	}
+	return nil
+}
+
+func (teambition *Teambition) PurgeTrash(ctx context.Context) error {
+	body := map[string]interface{}{
+		"orgId":   teambition.orgId,
+		"driveId": teambition.driveId,
+	}
+	err := teambition.jsonRequest(ctx, "POST", "https://pan.teambition.com/pan/api/nodes/archived/clear", &body, nil)
+	if err != nil {
+		return errors.Wrap(err, `error posting purge trash request`)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashErrorStatus(t *testing.T) {
+	teambition, server := newFakeTeambition(t)
+	forbidden := func(req *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusForbidden, map[string]string{"message": "forbidden"}
+	}
+	server.handle("POST /pan/api/nodes/restore", forbidden)
+	server.handle("POST /pan/api/nodes/delete", forbidden)
+	server.handle("POST /pan/api/nodes/archived/clear", forbidden)
+
+	node := &Node{NodeId: "a", ParentId: "root", Name: "a"}
+	require.Error(t, teambition.Restore(context.Background(), node))
+	require.Error(t, teambition.DeletePermanently(context.Background(), node))
+	require.Error(t, teambition.PurgeTrash(context.Background()))
+	require.Equal(t, 1, server.count("POST /pan/api/nodes/delete"))
+}